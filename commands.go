@@ -0,0 +1,474 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
+
+	"github.com/stolostron/klusterlet-addon-lease-controller/controllers"
+)
+
+// subcommands are run instead of the controller when the first argument is their name,
+// they return the exit code
+var subcommands = map[string]func(args []string) int{
+	"rbac":       runRBAC,
+	"check":      runCheck,
+	"renew-once": runRenewOnce,
+	"status":     runStatus,
+}
+
+const (
+	// exitSkipped is the exit code of renew-once when the lease is not renewed because the pod is not ready
+	exitSkipped = 3
+)
+
+// subcommandNames returns the sorted names of the subcommands
+func subcommandNames() []string {
+	names := []string{}
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newSubcommandFlagSet returns a flag set with the controller flags, so the subcommands use the same configuration.
+// It is parsed by parseSubcommandFlags.
+func newSubcommandFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	return fs
+}
+
+// parseSubcommandFlags parses the subcommand flags, also set by the environment and the config file as the controller flags
+func parseSubcommandFlags(fs *flag.FlagSet, args []string) error {
+	if err := configLoader.Load(fs, args); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintf(os.Stderr, "Invalid configuration:\n%s\n", formatErrors(err))
+		}
+		return err
+	}
+	return nil
+}
+
+// isFlagSet returns true if the flag is set on the command line, by the environment or by the config file
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// runRBAC prints the least-privilege Roles and RoleBindings for the flags in use
+func runRBAC(args []string) int {
+	fs := newSubcommandFlagSet("rbac")
+	namespace := fs.String("namespace", "", "The namespace of the pod, default the pod-namespace parameter.")
+	serviceAccount := fs.String("service-account", "klusterlet-addon-lease-controller", "The ServiceAccount of the pod.")
+	name := fs.String("name", "klusterlet-addon-lease-controller", "The name of the generated Roles and RoleBindings.")
+	watchPod := fs.Bool("watch-pod", false, "The pod readiness is checked, default true if POD_NAME is set and skip-pod-ready-check is not.")
+	if err := parseSubcommandFlags(fs, args); err != nil {
+		return 2
+	}
+	if !isFlagSet(fs, "watch-pod") {
+		*watchPod = !skipPodReadyCheck && podName != ""
+	}
+	if *namespace == "" {
+		*namespace = podNamespace
+	}
+	if leaseName == "" || leaseNamespace == "" || *namespace == "" {
+		fmt.Fprintln(os.Stderr, "the lease-name, lease-namespace and namespace parameters are required")
+		fs.Usage()
+		return 2
+	}
+	strategy, err := controllers.ParseRestartStrategy(restartStrategy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// the namespaces are resolved as the controller does, defaulting to the namespace of the pod
+	podNamespace = *namespace
+	hubSecretNamespace := watchNamespace()
+	o := &controllers.RBACOptions{
+		Name:                     *name,
+		Namespace:                *namespace,
+		ServiceAccount:           *serviceAccount,
+		HubConfigSecretNames:     splitList(hubConfigSecretName),
+		HubConfigSecretNamespace: hubSecretNamespace,
+		WatchPod:                 *watchPod,
+		RestartStrategy:          strategy,
+		CABundleConfigMap:        parseNamespacedName(hubCABundleConfigMap, hubSecretNamespace),
+		LeaseName:                leaseName,
+		LeaseNamespace:           leaseNamespace,
+		OwnerAddOnName:           ownerAddOnName(),
+	}
+	if restartBudget > 0 {
+		o.RestartBudgetConfigMap = restartBudgetConfigMapName()
+	}
+	if enableLeaderElection {
+		o.LeaderElectionID = leaderElectionID()
+		o.LeaderElectionNamespace = leaderElectionNamespaceName()
+	}
+
+	fmt.Println("# Managed cluster, bound to the ServiceAccount of the pod")
+	if err := printYAML(o.ManagedClusterRBAC()...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println("---")
+	fmt.Println("# Hub, to bind to the identity of the hub kubeconfig")
+	if err := printYAML(o.HubRBAC()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// runCheck validates the hub kubeconfig secret, read from the cluster or from a file
+func runCheck(args []string) int {
+	fs := newSubcommandFlagSet("check")
+	secretFile := fs.String("secret-file", "", "A YAML file containing the hub kubeconfig secret, default the hub-kubeconfig-secret read from the cluster.")
+	online := fs.Bool("online", false, "Check the lease permissions on the hub, the lease is not changed.")
+	expiryWarning := fs.Duration("expiry-warning", 7*24*time.Hour, "Fail the certificates expiring within this duration.")
+	if err := parseSubcommandFlags(fs, args); err != nil {
+		return 2
+	}
+	tlsMinVersion, err := controllers.ParseTLSVersion(hubTLSMinVersion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *online && (leaseName == "" || (leaseNamespace == "" && !deriveLeaseNamespace)) {
+		fmt.Fprintln(os.Stderr, "the lease-name and lease-namespace parameters are required to check online, unless derive-lease-namespace is set")
+		return 2
+	}
+
+	var reader client.Reader
+	if *secretFile == "" || hubCABundleConfigMap != "" {
+		if reader, err = newClient(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	var secret *corev1.Secret
+	if *secretFile != "" {
+		secret, err = readSecretFile(*secretFile)
+	} else {
+		secret, err = readHubSecret(reader)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	checker := &controllers.HubSecretChecker{
+		Options:              newHubClientOptions(reader, tlsMinVersion),
+		LeaseName:            leaseName,
+		LeaseNamespace:       leaseNamespace,
+		DeriveLeaseNamespace: deriveLeaseNamespace,
+		Online:               *online,
+		ExpiryWarning:        *expiryWarning,
+	}
+	results := checker.Check(context.TODO(), secret)
+	for _, result := range results {
+		fmt.Println(result)
+	}
+	if !controllers.CheckPassed(results) {
+		return 1
+	}
+	return 0
+}
+
+// runRenewOnce renews the lease once on each hub, for the CronJobs and the pre-stop hooks
+func runRenewOnce(args []string) int {
+	fs := newSubcommandFlagSet("renew-once")
+	if err := parseSubcommandFlags(fs, args); err != nil {
+		return 2
+	}
+	logOpts, err := loggerOptions()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fs.Usage()
+		return 2
+	}
+	ctrl.SetLogger(zap.New(logOpts...))
+	if traceSampleRatio < 0 || traceSampleRatio > 1 {
+		fmt.Fprintf(os.Stderr, "the trace-sample-ratio parameter must be between 0 and 1, got %v\n", traceSampleRatio)
+		return 2
+	}
+	shutdownTracing, err := controllers.SetupTracing(context.Background(), tracingOptions())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer flushTraces(shutdownTracing)
+	if leaseName == "" || (leaseNamespace == "" && !deriveLeaseNamespace) || hubConfigSecretName == "" {
+		fmt.Fprintln(os.Stderr, "the lease-name, lease-namespace (unless derive-lease-namespace is set) and hub-kubeconfig-secret parameters are required")
+		fs.Usage()
+		return 2
+	}
+	tlsMinVersion, err := controllers.ParseTLSVersion(hubTLSMinVersion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	labels, annotations, err := leaseMetadata()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid parameters:\n%s\n", formatErrors(err))
+		return 2
+	}
+
+	c, err := newClient()
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		return 1
+	}
+	hubClientOptions := newHubClientOptions(c, tlsMinVersion)
+	r := &controllers.LeaseReconciler{
+		Client:                          c,
+		APIReader:                       c,
+		LeaseName:                       leaseName,
+		LeaseNamespace:                  leaseNamespace,
+		LeaseDurationSeconds:            int32(leaseDurationSeconds),
+		BuildKubeClientWithSecretFunc:   hubClientOptions.BuildKubeClientWithSecret,
+		BuildHubEndpointsWithSecretFunc: hubClientOptions.BuildHubEndpointsWithSecret,
+		PodName:                         podName,
+		PodNamespace:                    podNamespace,
+		DeriveLeaseNamespace:            deriveLeaseNamespace,
+		CorrectClockSkew:                correctClockSkew,
+		LeaseLabels:                     labels,
+		LeaseAnnotations:                annotations,
+		OwnerAddOnName:                  ownerAddOnName(),
+		SkipPodReadyCheck:               skipPodReadyCheck,
+	}
+
+	code := 0
+	for _, name := range splitList(hubConfigSecretName) {
+		secret := &corev1.Secret{}
+		key := types.NamespacedName{Name: name, Namespace: watchNamespace()}
+		if err := c.Get(context.TODO(), key, secret); err != nil {
+			setupLog.Error(err, "unable to read the hub kubeconfig secret", "secret", key)
+			code = 1
+			continue
+		}
+		switch err := r.RenewOnce(context.TODO(), secret); {
+		case err == controllers.ErrPodNotReady:
+			setupLog.Info("Lease not renewed, the pod is not ready", "pod", r.PodNamespace+"/"+r.PodName)
+			return exitSkipped
+		case err != nil:
+			setupLog.Error(err, "unable to renew the lease", "secret", key)
+			code = 1
+		default:
+			setupLog.Info("Lease renewed", "lease", leaseNamespace+"/"+leaseName, "secret", key)
+		}
+	}
+	return code
+}
+
+// runStatus prints the state of the lease on each hub and of the pod
+func runStatus(args []string) int {
+	fs := newSubcommandFlagSet("status")
+	output := fs.String("output", "text", "The output format: text or json.")
+	graceFactor := fs.Float64("grace-factor", controllers.DefaultLeaseGraceFactor, "The number of lease durations after which the hub considers the lease expired.")
+	if err := parseSubcommandFlags(fs, args); err != nil {
+		return 2
+	}
+	if leaseName == "" || (leaseNamespace == "" && !deriveLeaseNamespace) || hubConfigSecretName == "" {
+		fmt.Fprintln(os.Stderr, "the lease-name, lease-namespace (unless derive-lease-namespace is set) and hub-kubeconfig-secret parameters are required")
+		fs.Usage()
+		return 2
+	}
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", *output)
+		return 2
+	}
+	tlsMinVersion, err := controllers.ParseTLSVersion(hubTLSMinVersion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	c, err := newClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	hubClientOptions := newHubClientOptions(c, tlsMinVersion)
+	r := &controllers.LeaseReconciler{
+		Client:                          c,
+		APIReader:                       c,
+		LeaseName:                       leaseName,
+		LeaseNamespace:                  leaseNamespace,
+		BuildKubeClientWithSecretFunc:   hubClientOptions.BuildKubeClientWithSecret,
+		BuildHubEndpointsWithSecretFunc: hubClientOptions.BuildHubEndpointsWithSecret,
+		PodName:                         podName,
+		PodNamespace:                    podNamespace,
+		DeriveLeaseNamespace:            deriveLeaseNamespace,
+		OwnerAddOnName:                  ownerAddOnName(),
+		PreflightFunc:                   controllers.ReviewAccess,
+		SkipPodReadyCheck:               skipPodReadyCheck,
+	}
+
+	code := 0
+	statuses := []*controllers.LeaseStatus{}
+	for _, name := range splitList(hubConfigSecretName) {
+		secret := &corev1.Secret{}
+		key := types.NamespacedName{Name: name, Namespace: watchNamespace()}
+		var status *controllers.LeaseStatus
+		if err := c.Get(context.TODO(), key, secret); err != nil {
+			status = &controllers.LeaseStatus{
+				Hub:            name,
+				LeaseNamespace: leaseNamespace,
+				LeaseName:      leaseName,
+				GraceFactor:    *graceFactor,
+				Error:          fmt.Sprintf("unable to read the hub kubeconfig secret %s: %v", key, err),
+			}
+		} else {
+			status = r.LeaseStatus(context.TODO(), secret, *graceFactor, time.Now())
+		}
+		if status.Error != "" {
+			code = 1
+		}
+		statuses = append(statuses, status)
+	}
+
+	if *output == "json" {
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(string(data))
+		return code
+	}
+	for i, status := range statuses {
+		if i > 0 {
+			fmt.Println()
+		}
+		printLeaseStatus(status)
+	}
+	return code
+}
+
+// printLeaseStatus prints the lease status for humans
+func printLeaseStatus(status *controllers.LeaseStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintf(w, "Hub:\t%s\n", status.Hub)
+	if status.Server != "" {
+		fmt.Fprintf(w, "Server:\t%s\n", status.Server)
+	}
+	fmt.Fprintf(w, "Lease:\t%s/%s\n", status.LeaseNamespace, status.LeaseName)
+	if status.Error != "" {
+		fmt.Fprintf(w, "Error:\t%s\n", status.Error)
+	}
+	if status.PodReady != nil {
+		fmt.Fprintf(w, "Pod ready:\t%t\n", *status.PodReady)
+	}
+	if len(status.MissingPermissions) != 0 {
+		fmt.Fprintf(w, "Missing permissions:\t%s\n", strings.Join(status.MissingPermissions, ", "))
+	}
+	if status.Error != "" {
+		return
+	}
+	if !status.Found {
+		fmt.Fprintf(w, "Found:\tfalse\n")
+		return
+	}
+	holder := status.HolderIdentity
+	if holder == "" {
+		holder = "<none>"
+	}
+	fmt.Fprintf(w, "Holder:\t%s\n", holder)
+	fmt.Fprintf(w, "Lease duration:\t%ds\n", status.LeaseDurationSeconds)
+	if status.RenewTime == nil {
+		fmt.Fprintf(w, "Renew time:\t<never>\n")
+	} else {
+		age := time.Duration(status.AgeSeconds * float64(time.Second)).Round(time.Second)
+		fmt.Fprintf(w, "Renew time:\t%s (%s ago, %.2f lease durations)\n",
+			status.RenewTime.Format(time.RFC3339), age, status.AgeDurations)
+	}
+	fmt.Fprintf(w, "Expired:\t%t (after %g lease durations)\n", status.Expired, status.GraceFactor)
+	if status.ClockSkewSeconds != nil {
+		fmt.Fprintf(w, "Clock skew:\t%s (hub time minus local time)\n", time.Duration(*status.ClockSkewSeconds*float64(time.Second)))
+	}
+}
+
+// newClient returns a client of the managed cluster, not cached
+func newClient() (client.Client, error) {
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return client.New(config, client.Options{Scheme: scheme})
+}
+
+// readHubSecret reads the first hub kubeconfig secret from the cluster
+func readHubSecret(reader client.Reader) (*corev1.Secret, error) {
+	names := splitList(hubConfigSecretName)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("the hub-kubeconfig-secret parameter is required")
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Name: names[0], Namespace: watchNamespace()}
+	if err := reader.Get(context.TODO(), key, secret); err != nil {
+		return nil, fmt.Errorf("unable to read the hub kubeconfig secret %s: %v", key, err)
+	}
+	return secret, nil
+}
+
+// readSecretFile reads a secret from a YAML file, the stringData is merged into the data
+func readSecretFile(file string) (*corev1.Secret, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{}
+	if err := yaml.Unmarshal(data, secret); err != nil {
+		return nil, fmt.Errorf("unable to parse the secret file %s: %v", file, err)
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for key, value := range secret.StringData {
+		secret.Data[key] = []byte(value)
+	}
+	return secret, nil
+}
+
+// printYAML prints the objects as a multi-document YAML
+func printYAML(objects ...runtime.Object) error {
+	for i, object := range objects {
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Println("---")
+		}
+		fmt.Print(string(data))
+	}
+	return nil
+}
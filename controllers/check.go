@@ -0,0 +1,195 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/util/cert"
+)
+
+// CheckResult is the result of a check of a hub kubeconfig secret
+type CheckResult struct {
+	Name    string
+	Passed  bool
+	Skipped bool
+	Message string
+}
+
+func (r CheckResult) String() string {
+	status := "FAIL"
+	switch {
+	case r.Skipped:
+		status = "SKIP"
+	case r.Passed:
+		status = "PASS"
+	}
+	return fmt.Sprintf("%s %s: %s", status, r.Name, r.Message)
+}
+
+// HubSecretChecker validates a hub kubeconfig secret offline: kubeconfig, certificates and client,
+// and online: the lease permissions, as CheckLeaseUpdaterClient does, plus the create and update permissions.
+type HubSecretChecker struct {
+	Options        *HubClientOptions
+	LeaseName      string
+	LeaseNamespace string
+	// DeriveLeaseNamespace derives the lease namespace as the controller does, LeaseNamespace is the fallback
+	DeriveLeaseNamespace bool
+	// ReviewSelfFunc returns the hub identity to derive the lease namespace, ReviewSelf if not set
+	ReviewSelfFunc IReviewSelf
+	// Online checks the lease permissions on the hub
+	Online bool
+	// ExpiryWarning fails the certificates expiring within this duration
+	ExpiryWarning time.Duration
+}
+
+// Check runs the checks, the online checks are skipped if the client can not be built
+func (c *HubSecretChecker) Check(ctx context.Context, secret *corev1.Secret) []CheckResult {
+	results := []CheckResult{}
+	pass := func(name, format string, args ...interface{}) {
+		results = append(results, CheckResult{Name: name, Passed: true, Message: fmt.Sprintf(format, args...)})
+	}
+	fail := func(name string, err error) {
+		results = append(results, CheckResult{Name: name, Message: err.Error()})
+	}
+
+	config, err := clientcmd.Load(secret.Data[hubKubeConfigKey])
+	if err == nil && len(secret.Data[hubKubeConfigKey]) == 0 {
+		err = fmt.Errorf("no %s key in secret %s/%s", hubKubeConfigKey, secret.Namespace, secret.Name)
+	}
+	if err != nil {
+		fail("kubeconfig", err)
+		return results
+	}
+	pass("kubeconfig", "current context %q", config.CurrentContext)
+
+	kubeContext := config.Contexts[config.CurrentContext]
+	if kubeContext == nil {
+		kubeContext = &clientcmdapi.Context{}
+	}
+	if cluster, ok := config.Clusters[kubeContext.Cluster]; ok {
+		results = append(results, c.checkCertificates("ca", cluster.CertificateAuthorityData, cluster.CertificateAuthority, secret)...)
+	}
+	if authInfo, ok := config.AuthInfos[kubeContext.AuthInfo]; ok {
+		results = append(results, c.checkCertificates("client-certificate", authInfo.ClientCertificateData, authInfo.ClientCertificate, secret)...)
+	}
+
+	endpoints, err := c.Options.BuildHubEndpointsWithSecret(secret)
+	if err != nil {
+		fail("client", err)
+		return results
+	}
+	pass("client", "hub servers %s", hubServers(endpoints))
+
+	if !c.Online {
+		return results
+	}
+	r := &LeaseReconciler{
+		LeaseNamespace:       c.LeaseNamespace,
+		DeriveLeaseNamespace: c.DeriveLeaseNamespace,
+		ReviewSelfFunc:       c.ReviewSelfFunc,
+	}
+	namespace, err := r.leaseNamespace(ctx, secret, endpoints[0].Client)
+	if err != nil {
+		fail("lease namespace", err)
+		return results
+	}
+	pass("lease namespace", "%s", namespace)
+	for _, endpoint := range endpoints {
+		results = append(results, c.checkLease(ctx, endpoint, namespace)...)
+	}
+	return results
+}
+
+// checkCertificates checks the expiry of the certificates, inline or in a file of the secret
+func (c *HubSecretChecker) checkCertificates(name string, data []byte, file string, secret *corev1.Secret) []CheckResult {
+	if data = secretFileData(data, file, secret); len(data) == 0 && file != "" {
+		return []CheckResult{{Name: name, Message: fmt.Sprintf("file %s not found in the secret", file)}}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	certs, err := cert.ParseCertsPEM(data)
+	if err != nil {
+		return []CheckResult{{Name: name, Message: err.Error()}}
+	}
+	results := []CheckResult{}
+	now := time.Now()
+	for _, crt := range certs {
+		result := CheckResult{Name: name}
+		switch {
+		case now.After(crt.NotAfter):
+			result.Message = fmt.Sprintf("%q expired on %s", crt.Subject.CommonName, crt.NotAfter.Format(time.RFC3339))
+		case now.Before(crt.NotBefore):
+			result.Message = fmt.Sprintf("%q not valid before %s", crt.Subject.CommonName, crt.NotBefore.Format(time.RFC3339))
+		case crt.NotAfter.Sub(now) < c.ExpiryWarning:
+			result.Message = fmt.Sprintf("%q expires soon, on %s", crt.Subject.CommonName, crt.NotAfter.Format(time.RFC3339))
+		default:
+			result.Passed = true
+			result.Message = fmt.Sprintf("%q valid until %s", crt.Subject.CommonName, crt.NotAfter.Format(time.RFC3339))
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// checkLease checks the get, create and update lease permissions on the hub API server, without changing the lease
+func (c *HubSecretChecker) checkLease(ctx context.Context, endpoint HubEndpoint, namespace string) []CheckResult {
+	leases := endpoint.Client.CoordinationV1().Leases(namespace)
+	prefix := "lease"
+	if endpoint.Server != "" {
+		prefix = fmt.Sprintf("lease on %s", endpoint.Server)
+	}
+	results := []CheckResult{}
+
+	lease, err := leases.Get(ctx, c.LeaseName, metav1.GetOptions{})
+	found := err == nil
+	if err != nil && !errors.IsNotFound(err) {
+		return append(results, CheckResult{Name: prefix + " get", Message: err.Error()})
+	}
+	if found {
+		results = append(results, CheckResult{Name: prefix + " get", Passed: true,
+			Message: fmt.Sprintf("lease %s/%s found", namespace, c.LeaseName)})
+	} else {
+		results = append(results, CheckResult{Name: prefix + " get", Passed: true,
+			Message: fmt.Sprintf("lease %s/%s not found", namespace, c.LeaseName)})
+	}
+
+	dryRun := []string{metav1.DryRunAll}
+	_, err = leases.Create(ctx, &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: c.LeaseName, Namespace: namespace},
+	}, metav1.CreateOptions{DryRun: dryRun})
+	// the authorization is checked before the existence
+	if err != nil && !errors.IsAlreadyExists(err) {
+		results = append(results, CheckResult{Name: prefix + " create", Message: err.Error()})
+	} else {
+		results = append(results, CheckResult{Name: prefix + " create", Passed: true, Message: "allowed (dry run)"})
+	}
+
+	if !found {
+		return append(results, CheckResult{Name: prefix + " update", Skipped: true, Message: "no lease to update"})
+	}
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now()}
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{DryRun: dryRun}); err != nil {
+		return append(results, CheckResult{Name: prefix + " update", Message: err.Error()})
+	}
+	return append(results, CheckResult{Name: prefix + " update", Passed: true, Message: "allowed (dry run)"})
+}
+
+// CheckPassed returns true if none of the checks failed
+func CheckPassed(results []CheckResult) bool {
+	for _, r := range results {
+		if !r.Passed && !r.Skipped {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,183 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/util/cert"
+)
+
+func TestHubSecretChecker_Check(t *testing.T) {
+	// the certificate is followed by its CA
+	clientCert, clientKey, err := cert.GenerateSelfSignedCertKey("system:open-cluster-management:cluster1:addon", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certKubeConfig := strings.Replace(string(newTestKubeConfig("https://api.hub.com:6443", nil)),
+		"    token: fake", "    client-certificate: tls.crt\n    client-key: tls.key", 1)
+	inlineCertKubeConfig := strings.Replace(string(newTestKubeConfig("https://api.hub.com:6443", nil)),
+		"    token: fake", "    client-certificate-data: "+base64.StdEncoding.EncodeToString(clientCert)+
+			"\n    client-key-data: "+base64.StdEncoding.EncodeToString(clientKey), 1)
+	tests := []struct {
+		name          string
+		secret        *corev1.Secret
+		expiryWarning time.Duration
+		wantChecks    []string
+		wantPassed    bool
+	}{
+		{
+			name:       "no kubeconfig",
+			secret:     &corev1.Secret{},
+			wantChecks: []string{"FAIL kubeconfig"},
+			wantPassed: false,
+		},
+		{
+			name: "invalid kubeconfig",
+			secret: &corev1.Secret{Data: map[string][]byte{
+				"kubeconfig": []byte("not a kubeconfig"),
+			}},
+			wantChecks: []string{"FAIL kubeconfig"},
+			wantPassed: false,
+		},
+		{
+			name: "valid token kubeconfig",
+			secret: &corev1.Secret{Data: map[string][]byte{
+				"kubeconfig": newTestKubeConfig("https://api.hub.com:6443", nil),
+			}},
+			wantChecks: []string{"PASS kubeconfig", "PASS client"},
+			wantPassed: true,
+		},
+		{
+			name: "client certificate file",
+			secret: &corev1.Secret{Data: map[string][]byte{
+				"kubeconfig": []byte(certKubeConfig),
+				"tls.crt":    clientCert,
+				"tls.key":    clientKey,
+			}},
+			wantChecks: []string{"PASS kubeconfig", "PASS client-certificate", "PASS client-certificate", "PASS client"},
+			wantPassed: true,
+		},
+		{
+			name: "client certificate file missing",
+			secret: &corev1.Secret{Data: map[string][]byte{
+				"kubeconfig": []byte(certKubeConfig),
+			}},
+			wantChecks: []string{"PASS kubeconfig", "FAIL client-certificate", "FAIL client"},
+			wantPassed: false,
+		},
+		{
+			name: "client certificate expiring",
+			secret: &corev1.Secret{Data: map[string][]byte{
+				"kubeconfig": []byte(inlineCertKubeConfig),
+			}},
+			expiryWarning: 100 * 365 * 24 * time.Hour,
+			wantChecks:    []string{"PASS kubeconfig", "FAIL client-certificate", "FAIL client-certificate", "PASS client"},
+			wantPassed:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &HubSecretChecker{Options: &HubClientOptions{}, ExpiryWarning: tt.expiryWarning}
+			results := c.Check(context.TODO(), tt.secret)
+			got := []string{}
+			for _, r := range results {
+				got = append(got, strings.SplitN(r.String(), ":", 2)[0])
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantChecks, ",") {
+				t.Errorf("HubSecretChecker.Check() = %v, want %v", results, tt.wantChecks)
+			}
+			if CheckPassed(results) != tt.wantPassed {
+				t.Errorf("CheckPassed() = %v, want %v", CheckPassed(results), tt.wantPassed)
+			}
+		})
+	}
+}
+
+func TestHubSecretChecker_Check_leaseNamespace(t *testing.T) {
+	secret := &corev1.Secret{Data: map[string][]byte{
+		"kubeconfig": newTestKubeConfig("https://api.hub.com:6443", nil),
+	}}
+	c := &HubSecretChecker{
+		Options:              &HubClientOptions{},
+		LeaseName:            "addon-lease",
+		Online:               true,
+		DeriveLeaseNamespace: true,
+		ReviewSelfFunc: func(ctx context.Context, hubClient kubernetes.Interface) (string, error) {
+			return "system:serviceaccount:ns:sa", nil
+		},
+	}
+	results := c.Check(context.TODO(), secret)
+	last := results[len(results)-1]
+	if last.Name != "lease namespace" || last.Passed {
+		t.Errorf("HubSecretChecker.Check() = %v, want the lease namespace check failed", results)
+	}
+}
+
+func TestHubSecretChecker_checkLease(t *testing.T) {
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: "addon-lease", Namespace: "cluster1"},
+	}
+	forbidden := func() clienttesting.ReactionFunc {
+		return func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.NewForbidden(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, "addon-lease", nil)
+		}
+	}
+	tests := []struct {
+		name       string
+		objects    []runtime.Object
+		forbidden  string
+		wantChecks []string
+	}{
+		{
+			name:       "lease found",
+			objects:    []runtime.Object{lease},
+			wantChecks: []string{"PASS lease get", "PASS lease create", "PASS lease update"},
+		},
+		{
+			name:       "lease not found",
+			wantChecks: []string{"PASS lease get", "PASS lease create", "SKIP lease update"},
+		},
+		{
+			name:       "update forbidden",
+			objects:    []runtime.Object{lease},
+			forbidden:  "update",
+			wantChecks: []string{"PASS lease get", "PASS lease create", "FAIL lease update"},
+		},
+		{
+			name:       "get forbidden",
+			forbidden:  "get",
+			wantChecks: []string{"FAIL lease get"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakekubeclient.NewSimpleClientset(tt.objects...)
+			if tt.forbidden != "" {
+				client.PrependReactor(tt.forbidden, "leases", forbidden())
+			}
+			c := &HubSecretChecker{LeaseName: "addon-lease"}
+			results := c.checkLease(context.TODO(), HubEndpoint{Client: client}, "cluster1")
+			got := []string{}
+			for _, r := range results {
+				got = append(got, strings.SplitN(r.String(), ":", 2)[0])
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantChecks, ",") {
+				t.Errorf("HubSecretChecker.checkLease() = %v, want %v", results, tt.wantChecks)
+			}
+		})
+	}
+}
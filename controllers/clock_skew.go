@@ -0,0 +1,126 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultClockSkewWarning is the default clock skew with the hub above which a warning is logged
+const DefaultClockSkewWarning = 5 * time.Second
+
+// ClockSkew estimates the clock skew with a hub API server, hub time minus local time,
+// from the Date header of its responses. The Date header has a one second resolution.
+type ClockSkew struct {
+	lock  sync.RWMutex
+	skew  time.Duration
+	valid bool
+	// now is the local clock, time.Now if not set
+	now func() time.Time
+}
+
+// Get returns the last estimated clock skew, false if none was estimated yet
+func (c *ClockSkew) Get() (time.Duration, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.skew, c.valid
+}
+
+// observe estimates the clock skew from the hub date of a response received between sent and received,
+// the hub date is assumed to be in the middle of the round trip
+func (c *ClockSkew) observe(hubDate, sent, received time.Time) {
+	local := sent.Add(received.Sub(sent) / 2)
+	// the Date header is truncated to the second
+	skew := hubDate.Add(500 * time.Millisecond).Sub(local).Round(time.Second)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.skew = skew
+	c.valid = true
+}
+
+func (c *ClockSkew) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// WrapTransport returns a transport estimating the clock skew from the responses of rt
+func (c *ClockSkew) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return &clockSkewTransport{skew: c, rt: rt}
+}
+
+type clockSkewTransport struct {
+	skew *ClockSkew
+	rt   http.RoundTripper
+}
+
+func (t *clockSkewTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sent := t.skew.clock()
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		t.skew.observe(date, sent, t.skew.clock())
+	}
+	return resp, nil
+}
+
+// hubTime returns the current time on the hub, the local time corrected by the estimated clock skew when
+// correctClockSkew is set. The estimated clock skew is reported, and logged when it is above the warning threshold.
+func (u *leaseUpdater) hubTime() time.Time {
+	now := time.Now()
+	skew, ok := u.getClockSkew().Get()
+	if !ok {
+		return now
+	}
+	hubClockSkewSeconds.WithLabelValues(u.hub, u.activeServer()).Set(skew.Seconds())
+	if u.clockSkewWarning > 0 {
+		above := skew >= u.clockSkewWarning || -skew >= u.clockSkewWarning
+		if above && !u.clockSkewWarned {
+			u.log().Info("WARNING: the clock skew with the hub (hub time minus local time) is above the warning threshold",
+				"skew", skew, "threshold", u.clockSkewWarning, "renewTimeLooks", skewEffect(skew))
+		} else if !above && u.clockSkewWarned {
+			u.log().Info("The clock skew with the hub is back under the warning threshold", "skew", skew, "threshold", u.clockSkewWarning)
+		}
+		u.clockSkewWarned = above
+	}
+	if u.correctClockSkew {
+		return now.Add(skew)
+	}
+	return now
+}
+
+// skewEffect returns how a lease renewed with the local time looks on the hub
+func skewEffect(skew time.Duration) string {
+	if skew > 0 {
+		return "expired"
+	}
+	return "future-dated"
+}
+
+// getClockSkew returns the clock skew estimation of the active hub endpoint, nil if none
+func (u *leaseUpdater) getClockSkew() *ClockSkew {
+	u.clientLock.RLock()
+	defer u.clientLock.RUnlock()
+	if u.activeEndpoint < len(u.endpoints) {
+		return u.endpoints[u.activeEndpoint].ClockSkew
+	}
+	return nil
+}
+
+// activeServer returns the active hub API server
+func (u *leaseUpdater) activeServer() string {
+	u.clientLock.RLock()
+	defer u.clientLock.RUnlock()
+	if u.activeEndpoint < len(u.endpoints) {
+		return u.endpoints[u.activeEndpoint].Server
+	}
+	return ""
+}
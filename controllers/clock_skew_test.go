@@ -0,0 +1,96 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestClockSkew_WrapTransport(t *testing.T) {
+	tests := []struct {
+		name string
+		skew time.Duration
+	}{
+		{name: "hub ahead", skew: 30 * time.Second},
+		{name: "hub behind", skew: -2 * time.Minute},
+		{name: "in sync", skew: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Date", time.Now().Add(tt.skew).UTC().Format(http.TimeFormat))
+			}))
+			defer server.Close()
+			c := &ClockSkew{}
+			if _, ok := c.Get(); ok {
+				t.Error("ClockSkew.Get() estimated before any response")
+			}
+			resp, err := (&http.Client{Transport: c.WrapTransport(http.DefaultTransport)}).Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			got, ok := c.Get()
+			if !ok {
+				t.Fatal("ClockSkew.Get() not estimated")
+			}
+			// the Date header has a one second resolution
+			if diff := got - tt.skew; diff > time.Second || diff < -time.Second {
+				t.Errorf("ClockSkew.Get() = %s, want %s", got, tt.skew)
+			}
+		})
+	}
+}
+
+func TestLeaseUpdater_renew_clockSkew(t *testing.T) {
+	skew := 10 * time.Minute
+	tests := []struct {
+		name             string
+		correctClockSkew bool
+		wantRenewTime    time.Duration // offset from the local time
+	}{
+		{name: "not corrected", correctClockSkew: false, wantRenewTime: 0},
+		{name: "corrected", correctClockSkew: true, wantRenewTime: skew},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hubClient := fakekubeclient.NewSimpleClientset(&coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{Name: leaseName, Namespace: leaseNamespace},
+			})
+			clockSkew := &ClockSkew{}
+			now := time.Now()
+			clockSkew.observe(now.Add(skew), now, now)
+			u := &leaseUpdater{
+				hub:              "hub-secret",
+				hubClient:        hubClient,
+				endpoints:        []HubEndpoint{{Server: "https://hub", Client: hubClient, ClockSkew: clockSkew}},
+				name:             leaseName,
+				namespace:        leaseNamespace,
+				clockSkewWarning: time.Minute,
+				correctClockSkew: tt.correctClockSkew,
+			}
+			if err := u.renew(context.TODO()); err != nil {
+				t.Fatalf("leaseUpdater.renew() error = %v", err)
+			}
+			if !u.clockSkewWarned {
+				t.Error("leaseUpdater.renew() no warning for a clock skew above the threshold")
+			}
+			lease, err := hubClient.CoordinationV1().Leases(leaseNamespace).Get(context.TODO(), leaseName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			offset := lease.Spec.RenewTime.Sub(time.Now())
+			if diff := offset - tt.wantRenewTime; diff > 2*time.Second || diff < -2*time.Second {
+				t.Errorf("leaseUpdater.renew() renew time offset = %s, want %s", offset, tt.wantRenewTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	cryptox509 "crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+
+	"golang.org/x/net/http/httpproxy"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// hubKubeConfigKey is the key of the hub kubeconfig in the hub secret
+	hubKubeConfigKey = "kubeconfig"
+	// hubProxyURLKey is the key of the optional proxy URL in the hub secret
+	hubProxyURLKey = "proxy-url"
+	// DefaultCABundleKey is the default key of the CA bundle in the CA bundle ConfigMap
+	DefaultCABundleKey = "ca-bundle.crt"
+)
+
+// IBuildHubEndpointsWithSecret a function which convert a secret to the list of hub endpoints
+type IBuildHubEndpointsWithSecret func(secret *corev1.Secret) ([]HubEndpoint, error)
+
+// HubEndpoint is a hub API server and the client connected to it
+type HubEndpoint struct {
+	Server string
+	Client kubernetes.Interface
+	// ClockSkew is estimated from the responses of the hub API server, optional
+	ClockSkew *ClockSkew
+}
+
+// HubClientOptions defines how the hub clients are built from the hub kubeconfig secret
+type HubClientOptions struct {
+	// Servers overrides the hub API servers of the kubeconfig, the first one is the preferred one.
+	// If empty, the server of the current context is used first followed by the servers of the other clusters.
+	Servers []string
+	// ProxyURL is the proxy used to connect to the hub, the hosts listed in NO_PROXY are not proxied.
+	// The proxy-url key of the hub secret takes precedence, if none is set HTTPS_PROXY is used.
+	ProxyURL string
+	// CABundleConfigMap is the optional ConfigMap containing extra CAs trusted for the hub connection
+	CABundleConfigMap types.NamespacedName
+	// CABundleKey is the key of the CA bundle in the ConfigMap, default DefaultCABundleKey
+	CABundleKey string
+	// Reader is used to read the CA bundle ConfigMap
+	Reader client.Reader
+	// TLSPolicy is enforced on the hub connection, a violation is returned as a HubPolicyError
+	TLSPolicy HubTLSPolicy
+	// AllowedServers restricts the hub API servers, a violation is returned as a HubPolicyError
+	AllowedServers HubServerAllowList
+}
+
+// BuildKubeClientWithSecret builds a client for the current context of the kubeconfig
+func BuildKubeClientWithSecret(secret *corev1.Secret) (kubernetes.Interface, error) {
+	return (&HubClientOptions{}).BuildKubeClientWithSecret(secret)
+}
+
+// BuildKubeClientWithSecret builds a client for the preferred hub API server
+func (o *HubClientOptions) BuildKubeClientWithSecret(secret *corev1.Secret) (kubernetes.Interface, error) {
+	endpoints, err := o.buildHubEndpoints(secret, false)
+	if err != nil {
+		return nil, err
+	}
+	return endpoints[0].Client, nil
+}
+
+// BuildHubEndpointsWithSecret builds a client for each hub API server, the preferred one first
+func (o *HubClientOptions) BuildHubEndpointsWithSecret(secret *corev1.Secret) ([]HubEndpoint, error) {
+	return o.buildHubEndpoints(secret, true)
+}
+
+func (o *HubClientOptions) buildHubEndpoints(secret *corev1.Secret, all bool) ([]HubEndpoint, error) {
+	restConfigs, err := o.buildHubRestConfigs(secret, all)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]HubEndpoint, 0, len(restConfigs))
+	for _, restConfig := range restConfigs {
+		clockSkew := &ClockSkew{}
+		restConfig.Wrap(clockSkew.WrapTransport)
+		restConfig.Wrap(TraceTransport)
+		client, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, HubEndpoint{Server: restConfig.Host, Client: client, ClockSkew: clockSkew})
+	}
+	return endpoints, nil
+}
+
+// buildHubRestConfigs returns the rest config of each hub API server, the preferred one first
+func (o *HubClientOptions) buildHubRestConfigs(secret *corev1.Secret, all bool) ([]*rest.Config, error) {
+	tempdir, err := ioutil.TempDir("", "kube")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempdir)
+
+	for key, data := range secret.Data {
+		if err := ioutil.WriteFile(path.Join(tempdir, key), data, 0600); err != nil {
+			return nil, err
+		}
+	}
+	rawConfig, err := clientcmd.LoadFromFile(path.Join(tempdir, hubKubeConfigKey))
+	if err != nil {
+		return nil, err
+	}
+	if err := clientcmd.ResolveLocalPaths(rawConfig); err != nil {
+		return nil, err
+	}
+	proxy, err := o.proxy(secret)
+	if err != nil {
+		return nil, err
+	}
+	caBundle, err := o.loadCABundle()
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := o.endpointOverrides(rawConfig)
+	if !all {
+		overrides = overrides[:1]
+	}
+	restConfigs := make([]*rest.Config, 0, len(overrides))
+	for _, override := range overrides {
+		restConfig, err := clientcmd.NewDefaultClientConfig(*rawConfig, override).ClientConfig()
+		if err != nil {
+			return nil, err
+		}
+		if err := o.AllowedServers.check(restConfig.Host); err != nil {
+			return nil, err
+		}
+		// the files are removed with the tempdir, their content must be kept in the config
+		if err := rest.LoadTLSFiles(restConfig); err != nil {
+			return nil, err
+		}
+		if proxy != nil {
+			restConfig.Proxy = proxy
+		}
+		// without CA in the kubeconfig the system CAs are trusted, they are kept along the CA bundle
+		systemRoots := len(restConfig.CAData) == 0
+		if len(caBundle) != 0 && !restConfig.Insecure && !systemRoots {
+			restConfig.CAData = mergeCABundle(restConfig.CAData, caBundle)
+		}
+		// the pinning applies to all the trusted CAs, the CA bundle included
+		if err := o.TLSPolicy.checkCA(restConfig); err != nil {
+			return nil, err
+		}
+		if len(caBundle) != 0 && !restConfig.Insecure && systemRoots {
+			if err := trustSystemRootsWithCABundle(restConfig, caBundle); err != nil {
+				return nil, err
+			}
+		}
+		if err := o.TLSPolicy.apply(restConfig); err != nil {
+			return nil, err
+		}
+		restConfigs = append(restConfigs, restConfig)
+	}
+	return restConfigs, nil
+}
+
+// endpointOverrides returns the kubeconfig overrides selecting each hub API server, the preferred one first
+func (o *HubClientOptions) endpointOverrides(rawConfig *clientcmdapi.Config) []*clientcmd.ConfigOverrides {
+	overrides := []*clientcmd.ConfigOverrides{}
+	if len(o.Servers) != 0 {
+		for _, server := range o.Servers {
+			overrides = append(overrides, &clientcmd.ConfigOverrides{
+				ClusterInfo: clientcmdapi.Cluster{Server: server},
+			})
+		}
+		return overrides
+	}
+
+	// the current context first
+	overrides = append(overrides, &clientcmd.ConfigOverrides{})
+	servers := map[string]bool{}
+	if context, ok := rawConfig.Contexts[rawConfig.CurrentContext]; ok {
+		if cluster, ok := rawConfig.Clusters[context.Cluster]; ok {
+			servers[cluster.Server] = true
+		}
+	}
+
+	names := make([]string, 0, len(rawConfig.Clusters))
+	for name := range rawConfig.Clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		server := rawConfig.Clusters[name].Server
+		if servers[server] {
+			continue
+		}
+		servers[server] = true
+		overrides = append(overrides, &clientcmd.ConfigOverrides{
+			Context: clientcmdapi.Context{Cluster: name},
+		})
+	}
+	return overrides
+}
+
+// proxy returns the proxy function of the hub secret or of the options,
+// nil to use the proxy defined by the environment.
+func (o *HubClientOptions) proxy(secret *corev1.Secret) (func(*http.Request) (*url.URL, error), error) {
+	proxyURL := o.ProxyURL
+	if secretProxyURL, ok := secret.Data[hubProxyURLKey]; ok {
+		proxyURL = string(secretProxyURL)
+	}
+	if proxyURL == "" {
+		return nil, nil
+	}
+	if _, err := url.Parse(proxyURL); err != nil {
+		return nil, fmt.Errorf("invalid hub proxy url %q: %v", proxyURL, err)
+	}
+	proxyConfig := &httpproxy.Config{
+		HTTPProxy:  proxyURL,
+		HTTPSProxy: proxyURL,
+		NoProxy:    httpproxy.FromEnvironment().NoProxy,
+	}
+	proxyFunc := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}, nil
+}
+
+// loadCABundle reads the extra CAs trusted for the hub connection
+func (o *HubClientOptions) loadCABundle() ([]byte, error) {
+	if o.CABundleConfigMap.Name == "" {
+		return nil, nil
+	}
+	cm := &corev1.ConfigMap{}
+	if err := o.Reader.Get(context.TODO(), o.CABundleConfigMap, cm); err != nil {
+		return nil, err
+	}
+	key := o.CABundleKey
+	if key == "" {
+		key = DefaultCABundleKey
+	}
+	caBundle, ok := cm.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("key %s not found in CA bundle configmap %s", key, o.CABundleConfigMap)
+	}
+	return []byte(caBundle), nil
+}
+
+// mergeCABundle appends the CA bundle to the CAs of the kubeconfig
+func mergeCABundle(caData, caBundle []byte) []byte {
+	merged := append([]byte{}, caData...)
+	if len(merged) != 0 && merged[len(merged)-1] != '\n' {
+		merged = append(merged, '\n')
+	}
+	return append(merged, caBundle...)
+}
+
+// trustSystemRootsWithCABundle sets a transport trusting the system CAs and the CA bundle, for a kubeconfig without CA.
+// The CA data of the rest config would replace the system CAs.
+func trustSystemRootsWithCABundle(restConfig *rest.Config, caBundle []byte) error {
+	tlsConfig, err := rest.TLSConfigFor(restConfig)
+	if err != nil {
+		return err
+	}
+	if tlsConfig == nil {
+		// no TLS option, the system CAs are used
+		tlsConfig = &tls.Config{}
+	}
+	roots, err := cryptox509.SystemCertPool()
+	if err != nil {
+		leaseLog.Error(err, "Unable to load the system CAs, only the CA bundle is trusted")
+		roots = cryptox509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(caBundle) {
+		return fmt.Errorf("no certificate found in the CA bundle")
+	}
+	tlsConfig.RootCAs = roots
+	// the TLS options can not be set with a custom transport, they are now part of the transport
+	restConfig.Transport = utilnet.SetTransportDefaults(&http.Transport{
+		TLSClientConfig: tlsConfig,
+		Proxy:           restConfig.Proxy,
+	})
+	restConfig.TLSClientConfig = rest.TLSClientConfig{}
+	return nil
+}
+
+// hubServers returns the hub API servers of the endpoints
+func hubServers(endpoints []HubEndpoint) string {
+	servers := make([]string, len(endpoints))
+	for i, e := range endpoints {
+		servers[i] = e.Server
+	}
+	return fmt.Sprintf("%v", servers)
+}
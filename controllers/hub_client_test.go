@@ -0,0 +1,410 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"bytes"
+	"crypto/tls"
+	cryptox509 "crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/cert"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const multiClusterKubeConfig = `
+apiVersion: v1
+clusters:
+- cluster:
+    insecure-skip-tls-verify: true
+    server: https://api-b.fake.com:6443
+  name: cluster-b
+- cluster:
+    insecure-skip-tls-verify: true
+    server: https://api-a.fake.com:6443
+  name: cluster-a
+- cluster:
+    insecure-skip-tls-verify: true
+    server: https://api-c.fake.com:6443
+  name: cluster-c
+contexts:
+- context:
+    cluster: cluster-b
+    namespace: default
+    user: default-auth
+  name: default-context
+current-context: default-context
+kind: Config
+preferences: {}
+users:
+- name: default-auth
+  user:
+    token: fake
+`
+
+func TestHubClientOptions_BuildHubEndpointsWithSecret(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret1",
+			Namespace: "secret-namespace",
+		},
+		Data: map[string][]byte{
+			"kubeconfig": []byte(multiClusterKubeConfig),
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+	tests := []struct {
+		name        string
+		options     *HubClientOptions
+		secret      *corev1.Secret
+		wantServers []string
+		wantErr     bool
+	}{
+		{
+			name:    "servers of the kubeconfig",
+			options: &HubClientOptions{},
+			secret:  secret,
+			wantServers: []string{
+				"https://api-b.fake.com:6443",
+				"https://api-a.fake.com:6443",
+				"https://api-c.fake.com:6443",
+			},
+			wantErr: false,
+		},
+		{
+			name: "servers overridden",
+			options: &HubClientOptions{
+				Servers: []string{"https://api-x.fake.com:6443", "https://api-y.fake.com:6443"},
+			},
+			secret: secret,
+			wantServers: []string{
+				"https://api-x.fake.com:6443",
+				"https://api-y.fake.com:6443",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "no kubeconfig",
+			options: &HubClientOptions{},
+			secret:  &corev1.Secret{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.options.BuildHubEndpointsWithSecret(tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HubClientOptions.BuildHubEndpointsWithSecret() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			servers := []string{}
+			for _, e := range got {
+				if e.Client == nil {
+					t.Errorf("HubClientOptions.BuildHubEndpointsWithSecret() client of %s is nil", e.Server)
+				}
+				servers = append(servers, e.Server)
+			}
+			if !reflect.DeepEqual(servers, tt.wantServers) {
+				t.Errorf("HubClientOptions.BuildHubEndpointsWithSecret() = %v, want %v", servers, tt.wantServers)
+			}
+		})
+	}
+}
+
+// newTestCertificate returns a PEM encoded self-signed CA certificate
+func newTestCertificate(t *testing.T, host string) []byte {
+	certPEM, _, err := cert.GenerateSelfSignedCertKey(host, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the certificate is followed by its signing CA, only the certificate is kept
+	block, _ := pem.Decode(certPEM)
+	return pem.EncodeToMemory(block)
+}
+
+// newTestKubeConfig returns a kubeconfig for the server, trusting the CA or skipping the TLS verification if caData is nil
+func newTestKubeConfig(server string, caData []byte) []byte {
+	tls := "    insecure-skip-tls-verify: true"
+	if caData != nil {
+		tls = "    certificate-authority-data: " + base64.StdEncoding.EncodeToString(caData)
+	}
+	return []byte(fmt.Sprintf(`
+apiVersion: v1
+clusters:
+- cluster:
+%s
+    server: %s
+  name: default-cluster
+contexts:
+- context:
+    cluster: default-cluster
+    namespace: default
+    user: default-auth
+  name: default-context
+current-context: default-context
+kind: Config
+preferences: {}
+users:
+- name: default-auth
+  user:
+    token: fake
+`, tls, server))
+}
+
+func TestHubClientOptions_proxy(t *testing.T) {
+	defer os.Setenv("NO_PROXY", os.Getenv("NO_PROXY"))
+	os.Setenv("NO_PROXY", ".internal.com")
+	kubeconfig := newTestKubeConfig("https://api.hub.com:6443", nil)
+	tests := []struct {
+		name      string
+		options   *HubClientOptions
+		secret    *corev1.Secret
+		requested string
+		wantProxy string
+		wantErr   bool
+	}{
+		{
+			name:    "proxy from the environment",
+			options: &HubClientOptions{},
+			secret: &corev1.Secret{Data: map[string][]byte{
+				"kubeconfig": kubeconfig,
+			}},
+			requested: "https://api.hub.com:6443",
+			wantProxy: "",
+		},
+		{
+			name:    "proxy of the options",
+			options: &HubClientOptions{ProxyURL: "http://proxy.corp.com:3128"},
+			secret: &corev1.Secret{Data: map[string][]byte{
+				"kubeconfig": kubeconfig,
+			}},
+			requested: "https://api.hub.com:6443",
+			wantProxy: "http://proxy.corp.com:3128",
+		},
+		{
+			name:    "proxy of the secret",
+			options: &HubClientOptions{ProxyURL: "http://proxy.corp.com:3128"},
+			secret: &corev1.Secret{Data: map[string][]byte{
+				"kubeconfig": kubeconfig,
+				"proxy-url":  []byte("http://hub-proxy.corp.com:3128"),
+			}},
+			requested: "https://api.hub.com:6443",
+			wantProxy: "http://hub-proxy.corp.com:3128",
+		},
+		{
+			name:    "no proxy",
+			options: &HubClientOptions{ProxyURL: "http://proxy.corp.com:3128"},
+			secret: &corev1.Secret{Data: map[string][]byte{
+				"kubeconfig": kubeconfig,
+			}},
+			requested: "https://api.internal.com:6443",
+			wantProxy: "",
+		},
+		{
+			name:    "invalid proxy",
+			options: &HubClientOptions{ProxyURL: "http://proxy corp:3128"},
+			secret: &corev1.Secret{Data: map[string][]byte{
+				"kubeconfig": kubeconfig,
+			}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.options.buildHubRestConfigs(tt.secret, false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HubClientOptions.buildHubRestConfigs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if got[0].Proxy == nil {
+				if tt.wantProxy != "" {
+					t.Errorf("HubClientOptions.buildHubRestConfigs() no proxy, want %s", tt.wantProxy)
+				}
+				return
+			}
+			req, _ := http.NewRequest(http.MethodGet, tt.requested, nil)
+			proxy, err := got[0].Proxy(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			gotProxy := ""
+			if proxy != nil {
+				gotProxy = proxy.String()
+			}
+			if gotProxy != tt.wantProxy {
+				t.Errorf("HubClientOptions.buildHubRestConfigs() proxy = %s, want %s", gotProxy, tt.wantProxy)
+			}
+		})
+	}
+}
+
+func TestHubClientOptions_caBundle(t *testing.T) {
+	hubCA := newTestCertificate(t, "hub-ca.com")
+	extraCA := newTestCertificate(t, "corporate-ca.com")
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "hub-ca-bundle",
+			Namespace: "addon-ns",
+		},
+		Data: map[string]string{
+			DefaultCABundleKey: string(extraCA),
+		},
+	}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, cm)
+	// neither CA nor insecure-skip-tls-verify, the system CAs are trusted
+	noCAKubeConfig := bytes.Replace(newTestKubeConfig("https://api.hub.com:6443", nil), []byte("    insecure-skip-tls-verify: true\n"), nil, 1)
+	tests := []struct {
+		name       string
+		options    *HubClientOptions
+		kubeconfig []byte
+		wantCAData []byte
+		// wantSystemRoots expects a transport trusting the system CAs and the CA bundle
+		wantSystemRoots bool
+		wantErr         bool
+	}{
+		{
+			name:       "no CA bundle",
+			options:    &HubClientOptions{Reader: c},
+			kubeconfig: newTestKubeConfig("https://api.hub.com:6443", hubCA),
+			wantCAData: hubCA,
+		},
+		{
+			name: "CA bundle merged",
+			options: &HubClientOptions{
+				Reader:            c,
+				CABundleConfigMap: types.NamespacedName{Namespace: "addon-ns", Name: "hub-ca-bundle"},
+			},
+			kubeconfig: newTestKubeConfig("https://api.hub.com:6443", hubCA),
+			wantCAData: append(append([]byte{}, hubCA...), extraCA...),
+		},
+		{
+			name: "CA bundle pinned",
+			options: &HubClientOptions{
+				Reader:            c,
+				CABundleConfigMap: types.NamespacedName{Namespace: "addon-ns", Name: "hub-ca-bundle"},
+				TLSPolicy:         HubTLSPolicy{CAFingerprints: []string{testFingerprint(hubCA), testFingerprint(extraCA)}},
+			},
+			kubeconfig: newTestKubeConfig("https://api.hub.com:6443", hubCA),
+			wantCAData: append(append([]byte{}, hubCA...), extraCA...),
+		},
+		{
+			name: "CA bundle not pinned",
+			options: &HubClientOptions{
+				Reader:            c,
+				CABundleConfigMap: types.NamespacedName{Namespace: "addon-ns", Name: "hub-ca-bundle"},
+				TLSPolicy:         HubTLSPolicy{CAFingerprints: []string{testFingerprint(hubCA)}},
+			},
+			kubeconfig: newTestKubeConfig("https://api.hub.com:6443", hubCA),
+			wantErr:    true,
+		},
+		{
+			name: "insecure kubeconfig",
+			options: &HubClientOptions{
+				Reader:            c,
+				CABundleConfigMap: types.NamespacedName{Namespace: "addon-ns", Name: "hub-ca-bundle"},
+			},
+			kubeconfig: newTestKubeConfig("https://api.hub.com:6443", nil),
+			wantCAData: nil,
+		},
+		{
+			name: "no CA in the kubeconfig",
+			options: &HubClientOptions{
+				Reader:            c,
+				CABundleConfigMap: types.NamespacedName{Namespace: "addon-ns", Name: "hub-ca-bundle"},
+			},
+			kubeconfig:      noCAKubeConfig,
+			wantSystemRoots: true,
+		},
+		{
+			name: "no CA in the kubeconfig with TLS minimum version",
+			options: &HubClientOptions{
+				Reader:            c,
+				CABundleConfigMap: types.NamespacedName{Namespace: "addon-ns", Name: "hub-ca-bundle"},
+				TLSPolicy:         HubTLSPolicy{MinVersion: tls.VersionTLS13},
+			},
+			kubeconfig:      noCAKubeConfig,
+			wantSystemRoots: true,
+		},
+		{
+			name: "no CA in the kubeconfig pinned",
+			options: &HubClientOptions{
+				Reader:            c,
+				CABundleConfigMap: types.NamespacedName{Namespace: "addon-ns", Name: "hub-ca-bundle"},
+				TLSPolicy:         HubTLSPolicy{CAFingerprints: []string{testFingerprint(extraCA)}},
+			},
+			kubeconfig: noCAKubeConfig,
+			wantErr:    true,
+		},
+		{
+			name: "CA bundle configmap not found",
+			options: &HubClientOptions{
+				Reader:            c,
+				CABundleConfigMap: types.NamespacedName{Namespace: "addon-ns", Name: "not-found"},
+			},
+			kubeconfig: newTestKubeConfig("https://api.hub.com:6443", hubCA),
+			wantErr:    true,
+		},
+		{
+			name: "CA bundle key not found",
+			options: &HubClientOptions{
+				Reader:            c,
+				CABundleConfigMap: types.NamespacedName{Namespace: "addon-ns", Name: "hub-ca-bundle"},
+				CABundleKey:       "not-found",
+			},
+			kubeconfig: newTestKubeConfig("https://api.hub.com:6443", hubCA),
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &corev1.Secret{Data: map[string][]byte{"kubeconfig": tt.kubeconfig}}
+			got, err := tt.options.buildHubRestConfigs(secret, false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HubClientOptions.buildHubRestConfigs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(got[0].CAData, tt.wantCAData) {
+				t.Errorf("HubClientOptions.buildHubRestConfigs() CAData = %s, want %s", got[0].CAData, tt.wantCAData)
+			}
+			transport, ok := got[0].Transport.(*http.Transport)
+			if ok != tt.wantSystemRoots {
+				t.Errorf("HubClientOptions.buildHubRestConfigs() transport set = %v, want %v", ok, tt.wantSystemRoots)
+				return
+			}
+			if !ok {
+				return
+			}
+			if transport.TLSClientConfig.MinVersion != tt.options.TLSPolicy.MinVersion {
+				t.Errorf("HubClientOptions.buildHubRestConfigs() TLS minimum version = %d, want %d",
+					transport.TLSClientConfig.MinVersion, tt.options.TLSPolicy.MinVersion)
+			}
+			certs, err := cert.ParseCertsPEM(extraCA)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := certs[0].Verify(cryptox509.VerifyOptions{Roots: transport.TLSClientConfig.RootCAs}); err != nil {
+				t.Errorf("HubClientOptions.buildHubRestConfigs() the CA bundle is not trusted: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// ReasonInsecureHubConnection is the reason of the policy error raised when the kubeconfig skips the TLS verification
+	ReasonInsecureHubConnection = "InsecureHubConnection"
+	// ReasonHubCAFingerprintMismatch is the reason of the policy error raised when the hub CA is not the pinned one
+	ReasonHubCAFingerprintMismatch = "HubCAFingerprintMismatch"
+	// ReasonHubServerNotAllowed is the reason of the policy error raised when the hub server is not in the allow-list
+	ReasonHubServerNotAllowed = "HubServerNotAllowed"
+)
+
+// HubPolicyError is returned when the hub connection defined by the hub secret violates the configured policy
+type HubPolicyError struct {
+	// Reason is a short CamelCase reason, used as reason of the Event
+	Reason  string
+	Message string
+}
+
+func (e *HubPolicyError) Error() string {
+	return e.Message
+}
+
+// HubTLSPolicy defines the TLS requirements of the hub connection
+type HubTLSPolicy struct {
+	// RejectInsecure rejects the kubeconfigs with insecure-skip-tls-verify
+	RejectInsecure bool
+	// MinVersion is the minimum TLS version of the hub connection, for example tls.VersionTLS12, 0 for the Go default
+	MinVersion uint16
+	// CAFingerprints pins the hub CAs, every CA trusted for the hub connection, the CAs of the kubeconfig
+	// and of the CA bundle, must have one of these SHA-256 fingerprints
+	CAFingerprints []string
+}
+
+// checkCA enforces the policy on the CAs trusted for the hub connection, the CAs of the kubeconfig merged with the CA bundle
+func (p *HubTLSPolicy) checkCA(restConfig *rest.Config) error {
+	if restConfig.Insecure {
+		if p.RejectInsecure || len(p.CAFingerprints) != 0 {
+			return &HubPolicyError{
+				Reason:  ReasonInsecureHubConnection,
+				Message: fmt.Sprintf("the hub kubeconfig skips the TLS verification of %s", restConfig.Host),
+			}
+		}
+		return nil
+	}
+	if len(p.CAFingerprints) == 0 {
+		return nil
+	}
+	fingerprints := caFingerprints(restConfig.CAData)
+	if len(fingerprints) == 0 {
+		return &HubPolicyError{
+			Reason:  ReasonHubCAFingerprintMismatch,
+			Message: fmt.Sprintf("the hub kubeconfig for %s has no CA, the system CAs can not be pinned", restConfig.Host),
+		}
+	}
+	pinned := map[string]bool{}
+	for _, fingerprint := range p.CAFingerprints {
+		pinned[normalizeFingerprint(fingerprint)] = true
+	}
+	unpinned := []string{}
+	for _, fingerprint := range fingerprints {
+		if !pinned[fingerprint] {
+			unpinned = append(unpinned, fingerprint)
+		}
+	}
+	if len(unpinned) != 0 {
+		return &HubPolicyError{
+			Reason:  ReasonHubCAFingerprintMismatch,
+			Message: fmt.Sprintf("the CAs %v trusted for the hub %s are not pinned", unpinned, restConfig.Host),
+		}
+	}
+	return nil
+}
+
+// apply sets the minimum TLS version of the connection
+func (p *HubTLSPolicy) apply(restConfig *rest.Config) error {
+	if p.MinVersion == 0 {
+		return nil
+	}
+	if transport, ok := restConfig.Transport.(*http.Transport); ok && transport.TLSClientConfig != nil {
+		// the TLS options are already part of the transport
+		transport.TLSClientConfig.MinVersion = p.MinVersion
+		return nil
+	}
+	tlsConfig, err := rest.TLSConfigFor(restConfig)
+	if err != nil {
+		return err
+	}
+	if tlsConfig == nil {
+		// plain http
+		return nil
+	}
+	tlsConfig.MinVersion = p.MinVersion
+	// the TLS options can not be set with a custom transport, they are now part of the transport
+	restConfig.Transport = utilnet.SetTransportDefaults(&http.Transport{
+		TLSClientConfig: tlsConfig,
+		Proxy:           restConfig.Proxy,
+	})
+	restConfig.TLSClientConfig = rest.TLSClientConfig{}
+	return nil
+}
+
+// HubServerAllowList restricts the hub API servers, an empty list allows all servers.
+// Each entry is either:
+//   - a URL pattern such as https://*.hub.example.com:6443, matching the whole server URL
+//   - a CIDR such as 10.0.0.0/16, matching the servers defined by their IP
+//   - a hostname pattern such as *.hub.example.com, matching the host of the server on any port
+type HubServerAllowList []string
+
+// check returns a HubPolicyError if the server is not allowed
+func (l HubServerAllowList) check(server string) error {
+	if len(l) == 0 {
+		return nil
+	}
+	serverURL, err := parseServerURL(server)
+	if err != nil {
+		return &HubPolicyError{
+			Reason:  ReasonHubServerNotAllowed,
+			Message: fmt.Sprintf("invalid hub server %q: %v", server, err),
+		}
+	}
+	for _, allowed := range l {
+		if serverAllowed(allowed, serverURL) {
+			return nil
+		}
+	}
+	return &HubPolicyError{
+		Reason:  ReasonHubServerNotAllowed,
+		Message: fmt.Sprintf("the hub server %s is not in the allowed servers %v", serverURL, []string(l)),
+	}
+}
+
+// serverAllowed returns true if the server matches the allow-list entry
+func serverAllowed(allowed string, serverURL *url.URL) bool {
+	if strings.Contains(allowed, "://") {
+		// only the scheme, host and path are matched, the user, query and fragment can not spoof the host
+		server := serverURL.Scheme + "://" + serverURL.Host + strings.TrimSuffix(serverURL.Path, "/")
+		matched, err := path.Match(strings.ToLower(strings.TrimSuffix(allowed, "/")), server)
+		return err == nil && matched
+	}
+	if _, cidr, err := net.ParseCIDR(allowed); err == nil {
+		ip := net.ParseIP(serverURL.Hostname())
+		return ip != nil && cidr.Contains(ip)
+	}
+	matched, err := path.Match(strings.ToLower(allowed), serverURL.Hostname())
+	return err == nil && matched
+}
+
+// parseServerURL parses a hub server as set in the rest config, the scheme is optional
+func parseServerURL(server string) (*url.URL, error) {
+	if !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+	serverURL, err := url.Parse(strings.ToLower(server))
+	if err != nil {
+		return nil, err
+	}
+	if serverURL.Hostname() == "" {
+		return nil, fmt.Errorf("no host")
+	}
+	return serverURL, nil
+}
+
+// caFingerprints returns the SHA-256 fingerprints of the PEM encoded certificates
+func caFingerprints(caData []byte) []string {
+	fingerprints := []string{}
+	for {
+		var block *pem.Block
+		block, caData = pem.Decode(caData)
+		if block == nil {
+			return fingerprints
+		}
+		sum := sha256.Sum256(block.Bytes)
+		fingerprints = append(fingerprints, hex.EncodeToString(sum[:]))
+	}
+}
+
+// normalizeFingerprint accepts the fingerprints in upper case and with colons as printed by openssl
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+}
+
+// ParseTLSVersion parses a TLS version such as 1.2 or VersionTLS12, an empty version is 0
+func ParseTLSVersion(version string) (uint16, error) {
+	switch strings.TrimPrefix(version, "VersionTLS") {
+	case "":
+		return 0, nil
+	case "1.0", "10":
+		return tls.VersionTLS10, nil
+	case "1.1", "11":
+		return tls.VersionTLS11, nil
+	case "1.2", "12":
+		return tls.VersionTLS12, nil
+	case "1.3", "13":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version %q", version)
+}
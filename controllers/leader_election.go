@@ -0,0 +1,65 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// minRetryPeriod is the minimum period between the leader election attempts
+const minRetryPeriod = time.Second
+
+// LeaderElectionDurations returns the durations of the leader election on the managed cluster,
+// derived from the interval between the hub lease renewals so a follower takes over the renewals
+// within one renew interval when the leader is lost: the leader lease lasts half of the renew interval.
+func LeaderElectionDurations(renewInterval time.Duration) (leaseDuration, renewDeadline, retryPeriod time.Duration) {
+	leaseDuration = renewInterval / 2
+	renewDeadline = leaseDuration * 2 / 3
+	retryPeriod = renewDeadline / 4
+	if retryPeriod < minRetryPeriod {
+		// the leader election requires leaseDuration > renewDeadline > retryPeriod * 1.2
+		retryPeriod = minRetryPeriod
+		renewDeadline = 2 * retryPeriod
+		leaseDuration = 3 * retryPeriod
+	}
+	return leaseDuration, renewDeadline, retryPeriod
+}
+
+// NeedLeaderElection returns false, the followers keep the pod status cached to take over quickly
+func (c *PodStatusCache) NeedLeaderElection() bool {
+	return false
+}
+
+// NeedLeaderElection returns false, the followers keep the hub secrets cached to take over quickly
+func (c *SecretCache) NeedLeaderElection() bool {
+	return false
+}
+
+// holdLease sets the holder identity of the lease, a change of holder is a lease transition
+func (u *leaseUpdater) holdLease(lease *coordinationv1.Lease, now time.Time) {
+	if u.holderIdentity == "" {
+		return
+	}
+	previous := ""
+	if lease.Spec.HolderIdentity != nil {
+		previous = *lease.Spec.HolderIdentity
+	}
+	if previous == u.holderIdentity {
+		return
+	}
+	holder := u.holderIdentity
+	lease.Spec.HolderIdentity = &holder
+	lease.Spec.AcquireTime = &metav1.MicroTime{Time: now}
+	if previous == "" && lease.Spec.LeaseTransitions == nil {
+		return
+	}
+	transitions := int32(1)
+	if lease.Spec.LeaseTransitions != nil {
+		transitions = *lease.Spec.LeaseTransitions + 1
+	}
+	lease.Spec.LeaseTransitions = &transitions
+	u.log().Info("Lease taken over", "holder", u.holderIdentity, "previousHolder", previous)
+}
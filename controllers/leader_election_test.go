@@ -0,0 +1,131 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLeaderElectionDurations(t *testing.T) {
+	tests := []struct {
+		renewInterval     time.Duration
+		wantLeaseDuration time.Duration
+		wantRenewDeadline time.Duration
+		wantRetryPeriod   time.Duration
+	}{
+		{60 * time.Second, 30 * time.Second, 20 * time.Second, 5 * time.Second},
+		{12 * time.Second, 6 * time.Second, 4 * time.Second, time.Second},
+		{2 * time.Second, 3 * time.Second, 2 * time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.renewInterval.String(), func(t *testing.T) {
+			leaseDuration, renewDeadline, retryPeriod := LeaderElectionDurations(tt.renewInterval)
+			if leaseDuration != tt.wantLeaseDuration || renewDeadline != tt.wantRenewDeadline || retryPeriod != tt.wantRetryPeriod {
+				t.Errorf("LeaderElectionDurations() = %s, %s, %s, want %s, %s, %s", leaseDuration, renewDeadline, retryPeriod,
+					tt.wantLeaseDuration, tt.wantRenewDeadline, tt.wantRetryPeriod)
+			}
+			if float64(renewDeadline) <= 1.2*float64(retryPeriod) || leaseDuration <= renewDeadline {
+				t.Errorf("LeaderElectionDurations() = %s, %s, %s, invalid leader election", leaseDuration, renewDeadline, retryPeriod)
+			}
+		})
+	}
+}
+
+func TestLeaseUpdater_holdLease(t *testing.T) {
+	holder := func(s string) *string { return &s }
+	transitions := func(i int32) *int32 { return &i }
+	now := time.Now()
+	tests := []struct {
+		name            string
+		holderIdentity  string
+		lease           coordinationv1.LeaseSpec
+		wantHolder      *string
+		wantTransitions *int32
+		wantAcquired    bool
+	}{
+		{
+			name:           "no identity",
+			holderIdentity: "",
+			lease:          coordinationv1.LeaseSpec{HolderIdentity: holder("pod-a")},
+			wantHolder:     holder("pod-a"),
+		},
+		{
+			name:           "new lease",
+			holderIdentity: "pod-a",
+			lease:          coordinationv1.LeaseSpec{},
+			wantHolder:     holder("pod-a"),
+			wantAcquired:   true,
+		},
+		{
+			name:           "same holder",
+			holderIdentity: "pod-a",
+			lease:          coordinationv1.LeaseSpec{HolderIdentity: holder("pod-a")},
+			wantHolder:     holder("pod-a"),
+		},
+		{
+			name:            "take over",
+			holderIdentity:  "pod-b",
+			lease:           coordinationv1.LeaseSpec{HolderIdentity: holder("pod-a")},
+			wantHolder:      holder("pod-b"),
+			wantTransitions: transitions(1),
+			wantAcquired:    true,
+		},
+		{
+			name:            "take over again",
+			holderIdentity:  "pod-a",
+			lease:           coordinationv1.LeaseSpec{HolderIdentity: holder("pod-b"), LeaseTransitions: transitions(1)},
+			wantHolder:      holder("pod-a"),
+			wantTransitions: transitions(2),
+			wantAcquired:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &leaseUpdater{holderIdentity: tt.holderIdentity}
+			lease := &coordinationv1.Lease{Spec: tt.lease}
+			u.holdLease(lease, now)
+			if *lease.Spec.HolderIdentity != *tt.wantHolder {
+				t.Errorf("leaseUpdater.holdLease() holder = %s, want %s", *lease.Spec.HolderIdentity, *tt.wantHolder)
+			}
+			if (lease.Spec.LeaseTransitions == nil) != (tt.wantTransitions == nil) ||
+				(tt.wantTransitions != nil && *lease.Spec.LeaseTransitions != *tt.wantTransitions) {
+				t.Errorf("leaseUpdater.holdLease() transitions = %v, want %v", lease.Spec.LeaseTransitions, tt.wantTransitions)
+			}
+			if (lease.Spec.AcquireTime != nil) != tt.wantAcquired {
+				t.Errorf("leaseUpdater.holdLease() acquire time = %v, want acquired %v", lease.Spec.AcquireTime, tt.wantAcquired)
+			}
+		})
+	}
+}
+
+func TestLeaseUpdater_renew_holder(t *testing.T) {
+	previous := "previous-leader"
+	hubClient := fakekubeclient.NewSimpleClientset(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: leaseName, Namespace: leaseNamespace},
+		Spec:       coordinationv1.LeaseSpec{HolderIdentity: &previous},
+	})
+	u := &leaseUpdater{
+		hub:            "hub-secret",
+		hubClient:      hubClient,
+		name:           leaseName,
+		namespace:      leaseNamespace,
+		holderIdentity: podName,
+	}
+	if err := u.renew(context.TODO()); err != nil {
+		t.Fatalf("leaseUpdater.renew() error = %v", err)
+	}
+	lease, err := hubClient.CoordinationV1().Leases(leaseNamespace).Get(context.TODO(), leaseName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != podName || *lease.Spec.LeaseTransitions != 1 {
+		t.Errorf("leaseUpdater.renew() holder = %v, transitions = %v, want %s, 1",
+			lease.Spec.HolderIdentity, lease.Spec.LeaseTransitions, podName)
+	}
+}
@@ -9,9 +9,6 @@ package controllers
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path"
 	"reflect"
 	"sync"
 	"time"
@@ -19,6 +16,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -26,12 +25,15 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 var (
@@ -42,7 +44,7 @@ var (
 type IBuildKubeClientWithSecret func(secret *corev1.Secret) (kubernetes.Interface, error)
 
 // ICheckLeaseUpdaterClient checks if a leaseUpdater has valid client
-type ICheckLeaseUpdaterClient func(u *leaseUpdater) bool
+type ICheckLeaseUpdaterClient func(ctx context.Context, u *leaseUpdater) bool
 
 // LeaseReconciler reconciles a Secret object
 type LeaseReconciler struct {
@@ -52,47 +54,139 @@ type LeaseReconciler struct {
 	LeaseName           string
 	LeaseNamespace      string
 	HubConfigSecretName string
+	// AdditionalHubConfigSecretNames are the secrets of other hubs where the lease is renewed at the same time
+	AdditionalHubConfigSecretNames []string
 	// Use a type because this allows to create a fake function
 	BuildKubeClientWithSecretFunc IBuildKubeClientWithSecret
-	LeaseDurationSeconds          int32
-	PodName                       string
-	PodNamespace                  string
-	leaseUpdater                  *leaseUpdater
-	cachedSecret                  *corev1.Secret
-	CheckLeaseUpdaterClient       ICheckLeaseUpdaterClient
+	// If set, used instead of BuildKubeClientWithSecretFunc to get all hub API servers for failover
+	BuildHubEndpointsWithSecretFunc IBuildHubEndpointsWithSecret
+	LeaseDurationSeconds            int32
+	PodName                         string
+	PodNamespace                    string
+	hubLeases                       map[string]*hubLease // keyed by hub secret name
+	CheckLeaseUpdaterClient         ICheckLeaseUpdaterClient
+	// Recorder records the Events on the hub secrets and the pod, optional
+	Recorder record.EventRecorder
+	// RestartStrategy defines how the pod is restarted when only the new hub secret works
+	RestartStrategy RestartStrategy
+	// RestartContainer is the container signaled by the RestartStrategyAnnotatePod strategy
+	RestartContainer string
+	// RestartBudget limits the automatic restarts
+	RestartBudget RestartBudget
+	// PodStatus serves the pod readiness, optional, the pod is read from the client if not set
+	PodStatus *PodStatusCache
+	// SecretCache caches only the hub secrets, optional, the secrets are watched through the manager cache if not set
+	SecretCache *SecretCache
+	// APIReader reads the objects read once, such as the pod to restart, without caching all the objects of their kind.
+	// Optional, the client is used if not set
+	APIReader client.Reader
+	// RenewInterval is the interval between the lease renewals, the lease duration if 0
+	RenewInterval time.Duration
+	// SkipPodReadyCheck renews the lease even if the pod is not ready
+	SkipPodReadyCheck bool
+	// LeaseLabels and LeaseAnnotations are set on the hub lease, the other labels and annotations are kept
+	LeaseLabels      map[string]string
+	LeaseAnnotations map[string]string
+	// OwnerAddOnName is the hub ManagedClusterAddOn, in the lease namespace, set as owner of the lease, optional
+	OwnerAddOnName string
+	// GetOwnerReferenceFunc reads the owner reference of the lease, GetManagedClusterAddOnOwnerReference if not set
+	GetOwnerReferenceFunc IGetOwnerReference
+	// DeriveLeaseNamespace derives the lease namespace from the hub secret and identity, LeaseNamespace is the fallback
+	DeriveLeaseNamespace bool
+	// ReviewSelfFunc returns the hub identity to derive the lease namespace, ReviewSelf if not set
+	ReviewSelfFunc IReviewSelf
+	// HolderIdentity is the holder identity of the hub lease, usually the pod name, optional.
+	// With the leader election, only the leader renews the lease so the holder follows the leadership.
+	HolderIdentity string
+	// ClockSkewWarning is the clock skew with the hub above which a warning is logged, 0 to not warn
+	ClockSkewWarning time.Duration
+	// CorrectClockSkew sets the renew time of the lease to the hub time, estimated with the clock skew
+	CorrectClockSkew bool
+	// VerifyRenewInterval is the interval between the verifications that the last renewal is visible on the hub
+	// and not overwritten by another agent, 0 to not verify
+	VerifyRenewInterval time.Duration
+	// PreflightFunc reviews the hub permissions before a lease updater starts, optional
+	PreflightFunc IPreflight
+	// settingsLock serializes the reconciliations and the changes of the settings
+	settingsLock sync.Mutex
+	// settingsChanged triggers the reconciliation of the hub secrets when the settings change
+	settingsChanged chan event.GenericEvent
+}
+
+// hubLease is the state of the lease renewed on a hub
+type hubLease struct {
+	leaseUpdater *leaseUpdater
+	cachedSecret *corev1.Secret
+	// restartPending is set when the restart budget suppressed the restart to use the new secret
+	restartPending bool
 }
 
 // leaseUpdater periodically updates the lease of a managed cluster
 type leaseUpdater struct {
+	hub               string // name of the hub secret
 	hubClient         kubernetes.Interface
+	endpoints         []HubEndpoint // hub API servers to fail over to
+	activeEndpoint    int           // index of the endpoint used by hubClient
+	clientLock        sync.RWMutex
 	namespace         string
 	name              string
 	lock              sync.Mutex
+	updateLock        sync.Mutex // serializes the lease updates
 	cancel            context.CancelFunc
+	renewInterval     time.Duration        // the lease duration if 0
 	checkPodIsRunning func() (bool, error) // callback function for checking if pod is running
+	// onPodReady registers a handler called when the pod becomes ready, optional
+	onPodReady         func(handler func()) (remove func())
+	removeReadyHandler func()
+	// desired spec of the lease, enforced on each renewal
+	leaseDurationSeconds int32
+	labels               map[string]string
+	annotations          map[string]string
+	owner                string // name of the owner of the lease
+	ownerReference       *metav1.OwnerReference
+	getOwnerReference    IGetOwnerReference
+	holderIdentity       string
+	clockSkewWarning     time.Duration
+	clockSkewWarned      bool // the clock skew is above the warning threshold
+	correctClockSkew     bool
+	verifyInterval       time.Duration     // 0 to not verify the renewals
+	renewed              *metav1.MicroTime // the last renew time written on the hub
+	conflict             bool              // another agent renews the lease
+	recordEvent          func(eventType, reason, message string)
 }
 
 func (r *LeaseReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	_ = context.Background()
+	ctx, span := tracer().Start(context.Background(), "Reconcile", trace.WithAttributes(attribute.String("hub", req.Name)))
+	result, err := r.reconcile(ctx, req)
+	endSpan(span, err)
+	return result, err
+}
+
+// reconcile starts the lease updater of the hub secret, restarts the pod when the secret changes, or stops the updater
+func (r *LeaseReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	_ = r.Log.WithValues("lease", req.NamespacedName)
+	r.settingsLock.Lock()
+	defer r.settingsLock.Unlock()
 
-	leaseLog.Info(fmt.Sprintf("processing %s", req.NamespacedName.Name))
+	log := leaseLog.WithValues("hub", req.Name)
+	log.Info("Processing the hub secret", "secret", req.NamespacedName.String())
 
-	if r.leaseUpdater == nil {
+	h := r.getHubLease(req.Name)
+	if h.leaseUpdater == nil && !r.SkipPodReadyCheck {
 		ready, err := r.checkPodIsRunning()
 		if err != nil {
 			return reconcile.Result{}, err
 		}
 		if !ready {
-			leaseLog.Info(fmt.Sprintf("Wait until pod %s/%s is ready", r.PodName, r.PodNamespace))
+			log.Info("Waiting until the pod is ready", "pod", r.PodName, "podNamespace", r.PodNamespace)
 			return reconcile.Result{Requeue: true, RequeueAfter: 10 * time.Second}, nil
 		}
 	}
 
 	instance := &corev1.Secret{}
 
-	if err := r.Get(
-		context.TODO(),
+	if err := r.secretReader().Get(
+		ctx,
 		types.NamespacedName{Namespace: req.Namespace, Name: req.Name},
 		instance,
 	); err != nil {
@@ -100,230 +194,519 @@ func (r *LeaseReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
-			if r.leaseUpdater == nil {
+			if h.leaseUpdater == nil {
 				return reconcile.Result{}, nil
 			}
-			r.leaseUpdater.stop(context.TODO())
+			h.leaseUpdater.stop(context.TODO())
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
-	if r.leaseUpdater == nil {
-		u, err := r.newUpdaterLease(instance)
+	if h.leaseUpdater == nil {
+		secret := instance
+		if h.cachedSecret != nil && r.PodName != "" && r.PodNamespace != "" {
+			// the updater stopped by ApplySettings is restarted with the secret the pod uses,
+			// so a pending change of the hub secret is still detected below
+			secret = h.cachedSecret
+		}
+		u, err := r.newUpdaterLease(ctx, secret)
 		if err != nil {
-			return reconcile.Result{}, err
+			return r.handleUpdaterLeaseError(secret, err)
 		}
-		if r.CheckLeaseUpdaterClient != nil && !r.CheckLeaseUpdaterClient(u) {
-			leaseLog.Info("Failed to use the current client for lease update. Requeue after 10 seconds.")
+		if r.CheckLeaseUpdaterClient != nil && !r.CheckLeaseUpdaterClient(ctx, u) && !u.failover(ctx) {
+			if secret != instance && !reflect.DeepEqual(instance.Data, secret.Data) {
+				// the secret in use no longer works, test the new one
+				if uNew, err := r.newUpdaterLease(ctx, instance); err != nil {
+					return r.handleUpdaterLeaseError(instance, err)
+				} else if r.CheckLeaseUpdaterClient(ctx, uNew) {
+					return r.rotateHubSecret(ctx, h, uNew, instance)
+				}
+			}
+			log.Info("Failed to use the current client for lease update", "requeueAfter", 10*time.Second)
 			return reconcile.Result{Requeue: true, RequeueAfter: 10 * time.Second}, nil
 		}
-		r.leaseUpdater = u
-		err = r.leaseUpdater.start(context.TODO(), &r.LeaseDurationSeconds)
+		if !r.preflight(ctx, u, instance) {
+			log.Info("Missing permissions on the hub for lease update", "requeueAfter", 60*time.Second)
+			return reconcile.Result{Requeue: true, RequeueAfter: 60 * time.Second}, nil
+		}
+		h.leaseUpdater = u
+		err = h.leaseUpdater.start(ctx, &r.LeaseDurationSeconds)
 		if err != nil {
-			r.leaseUpdater = nil
+			h.leaseUpdater = nil
 			return reconcile.Result{}, err
 		}
-		r.cachedSecret = instance
+		h.cachedSecret = secret
 	}
 
 	if instance.DeletionTimestamp != nil {
-		leaseLog.Info(fmt.Sprintf("stop lease for %s", req.NamespacedName.Name))
-		r.leaseUpdater.stop(context.TODO())
-		r.leaseUpdater = nil
+		log.Info("Stopping the lease, the hub secret is deleted")
+		h.leaseUpdater.stop(context.TODO())
+		h.leaseUpdater = nil
+		h.cachedSecret = nil
 		return reconcile.Result{}, nil
 	}
 
 	if r.PodName != "" && r.PodNamespace != "" &&
-		r.cachedSecret != nil && !reflect.DeepEqual(instance.Data, r.cachedSecret.Data) {
+		h.cachedSecret != nil && !reflect.DeepEqual(instance.Data, h.cachedSecret.Data) {
 		// test if the older kubeconfig doesn't work and the newer kubeconfig works
-		if r.CheckLeaseUpdaterClient != nil && !r.CheckLeaseUpdaterClient(r.leaseUpdater) {
-			if uNew, err := r.newUpdaterLease(instance); err != nil {
-				return reconcile.Result{}, err
-			} else if r.CheckLeaseUpdaterClient(uNew) {
+		if r.CheckLeaseUpdaterClient != nil && !r.CheckLeaseUpdaterClient(ctx, h.leaseUpdater) {
+			if uNew, err := r.newUpdaterLease(ctx, instance); err != nil {
+				return r.handleUpdaterLeaseError(instance, err)
+			} else if r.CheckLeaseUpdaterClient(ctx, uNew) {
 				//restart the pod if the newer one works
-				leaseLog.Info("Restarting pod to use new secret.")
-				if err := r.deletePod(); err != nil {
-					return reconcile.Result{}, err
-				}
-				return reconcile.Result{}, nil
+				return r.rotateHubSecret(ctx, h, uNew, instance)
 			}
 		}
 		if r.CheckLeaseUpdaterClient != nil {
-			leaseLog.Info("Detected secret changes, but new secret is not ready. Reque after 60 seconds.")
+			log.Info("Detected secret changes, but the new secret is not ready", "requeueAfter", 60*time.Second)
 			return reconcile.Result{Requeue: true, RequeueAfter: 60 * time.Second}, nil
 		}
 	}
 
+	if h.restartPending {
+		// the lease is already renewed with the new secret, the pod still has to restart to use it
+		log.Info("Retrying the pod restart suppressed by the restart budget")
+		retryAfter, err := r.restartPod()
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		h.restartPending = retryAfter > 0
+		if retryAfter > 0 {
+			return reconcile.Result{Requeue: true, RequeueAfter: retryAfter}, nil
+		}
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// rotateHubSecret restarts the pod to use the new hub secret. When the pod is not restarted, with the AnnotatePod
+// and None strategies or when the restart budget suppresses the restart, the lease is renewed with the new secret.
+func (r *LeaseReconciler) rotateHubSecret(ctx context.Context, h *hubLease, uNew *leaseUpdater, instance *corev1.Secret) (ctrl.Result, error) {
+	log := leaseLog.WithValues("hub", instance.Name)
+	log.Info("Restarting the pod to use the new secret")
+	retryAfter, err := r.restartPod()
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if retryAfter == 0 && r.RestartStrategy.restartsPod() {
+		return reconcile.Result{}, nil
+	}
+
+	log.Info("The pod is not restarted, renewing the lease with the new secret", "strategy", string(r.RestartStrategy))
+	if err := uNew.start(ctx, &r.LeaseDurationSeconds); err != nil {
+		return reconcile.Result{}, err
+	}
+	if h.leaseUpdater != nil {
+		h.leaseUpdater.stop(context.TODO())
+	}
+	h.leaseUpdater = uNew
+	h.cachedSecret = instance
+	h.restartPending = retryAfter > 0
+	if retryAfter > 0 {
+		return reconcile.Result{Requeue: true, RequeueAfter: retryAfter}, nil
+	}
 	return reconcile.Result{}, nil
 }
 
+// handleUpdaterLeaseError reports the hub secrets violating the hub connection policy,
+// they are not retried until the secret changes.
+func (r *LeaseReconciler) handleUpdaterLeaseError(instance *corev1.Secret, err error) (ctrl.Result, error) {
+	policyErr, ok := err.(*HubPolicyError)
+	if !ok {
+		return reconcile.Result{}, err
+	}
+	leaseLog.Error(err, "Hub secret rejected", "hub", instance.Name, "secret", instance.Namespace+"/"+instance.Name, "reason", policyErr.Reason)
+	hubPolicyViolationsTotal.WithLabelValues(instance.Name, policyErr.Reason).Inc()
+	r.recordEvent(instance, corev1.EventTypeWarning, policyErr.Reason, policyErr.Message)
+	return reconcile.Result{}, nil
+}
+
+// recordEvent records an Event if a recorder is set
+func (r *LeaseReconciler) recordEvent(object runtime.Object, eventType, reason, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(object, eventType, reason, message)
+	}
+}
+
+// getHubLease returns the lease state of the hub defined by the secret
+func (r *LeaseReconciler) getHubLease(secretName string) *hubLease {
+	if r.hubLeases == nil {
+		r.hubLeases = map[string]*hubLease{}
+	}
+	h, ok := r.hubLeases[secretName]
+	if !ok {
+		h = &hubLease{}
+		r.hubLeases[secretName] = h
+	}
+	return h
+}
+
+// hubConfigSecretNames returns the names of all hub secrets
+func (r *LeaseReconciler) hubConfigSecretNames() []string {
+	return append([]string{r.HubConfigSecretName}, r.AdditionalHubConfigSecretNames...)
+}
+
+// isHubConfigSecret returns true if the secret contains the kubeconfig of a hub
+func (r *LeaseReconciler) isHubConfigSecret(name string) bool {
+	for _, secretName := range r.hubConfigSecretNames() {
+		if name == secretName {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *LeaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Secret{}).
-		WithEventFilter(r.newSecretPredicate()).
-		Complete(r)
+	if r.PodStatus != nil {
+		if err := mgr.Add(r.PodStatus); err != nil {
+			return err
+		}
+	}
+	r.settingsChanged = make(chan event.GenericEvent, len(r.hubConfigSecretNames()))
+	if r.SecretCache == nil {
+		return ctrl.NewControllerManagedBy(mgr).
+			For(&corev1.Secret{}).
+			Watches(&source.Channel{Source: r.settingsChanged}, &handler.EnqueueRequestForObject{}).
+			WithEventFilter(r.newSecretPredicate()).
+			Complete(r)
+	}
+
+	if err := mgr.Add(r.SecretCache); err != nil {
+		return err
+	}
+	c, err := controller.New("secret", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+	for _, src := range r.SecretCache.Sources() {
+		if err := c.Watch(src, &handler.EnqueueRequestForObject{}, r.newSecretPredicate()); err != nil {
+			return err
+		}
+	}
+	return c.Watch(&source.Channel{Source: r.settingsChanged}, &handler.EnqueueRequestForObject{})
+}
+
+// secretReader returns the reader of the hub secrets
+func (r *LeaseReconciler) secretReader() client.Reader {
+	if r.SecretCache != nil {
+		return r.SecretCache
+	}
+	return r.Client
+}
+
+// reader returns the reader of the objects read once
+func (r *LeaseReconciler) reader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
 }
 
 func (r *LeaseReconciler) newSecretPredicate() predicate.Predicate {
 	return predicate.Predicate(predicate.Funcs{
 		CreateFunc: func(e event.CreateEvent) bool {
-			return e.Meta.GetName() == r.HubConfigSecretName
+			return r.isHubConfigSecret(e.Meta.GetName())
 		},
 		DeleteFunc: func(e event.DeleteEvent) bool {
-			return e.Meta.GetName() == r.HubConfigSecretName
+			return r.isHubConfigSecret(e.Meta.GetName())
 		},
 		UpdateFunc: func(e event.UpdateEvent) bool {
-			return e.MetaNew.GetName() == r.HubConfigSecretName
+			return r.isHubConfigSecret(e.MetaNew.GetName())
 		},
 	})
 }
 
-// deletePod delete the current pod
-func (r *LeaseReconciler) deletePod() error {
-	pod := &corev1.Pod{}
-	if err := r.Client.Get(context.TODO(),
-		types.NamespacedName{Name: r.PodName, Namespace: r.PodNamespace},
-		pod,
-	); err != nil {
-		leaseLog.Error(err, "failed to get pod")
-		return err
-	}
-	err := r.Client.Delete(context.TODO(), pod)
-	if err != nil {
-		leaseLog.Error(err, "failed to restart pod")
-		return err
-	}
-	return nil
-}
-
-//checkPodIsRunning check if the pod is ready
+// checkPodIsRunning check if the pod is ready
 func (r *LeaseReconciler) checkPodIsRunning() (bool, error) {
 	if r.PodName == "" || r.PodNamespace == "" {
 		return true, nil
 	}
+	if r.PodStatus != nil {
+		return r.PodStatus.IsReady()
+	}
 	pod := corev1.Pod{}
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: r.PodName, Namespace: r.PodNamespace}, &pod)
+	err := r.reader().Get(context.TODO(), types.NamespacedName{Name: r.PodName, Namespace: r.PodNamespace}, &pod)
 	if err != nil {
 		return false, err
 	}
+	return isPodReady(&pod), nil
+}
 
-	// check if the pod has condition ready=true
+// isPodReady checks if the pod has condition ready=true
+func isPodReady(pod *corev1.Pod) bool {
 	for _, c := range pod.Status.Conditions {
 		if c.Type == corev1.PodReady {
-			return c.Status == corev1.ConditionTrue, nil
+			return c.Status == corev1.ConditionTrue
 		}
 	}
-
-	return false, nil
+	return false
 }
 
-func (r *LeaseReconciler) newUpdaterLease(instance *corev1.Secret) (*leaseUpdater, error) {
-	clientset, err := r.BuildKubeClientWithSecretFunc(instance)
+func (r *LeaseReconciler) newUpdaterLease(ctx context.Context, instance *corev1.Secret) (u *leaseUpdater, err error) {
+	ctx, span := tracer().Start(ctx, "newUpdaterLease", trace.WithAttributes(attribute.String("hub", instance.Name)))
+	defer func() { endSpan(span, err) }()
+	endpoints, err := r.buildHubEndpoints(instance)
 	if err != nil {
-		leaseLog.Error(err, "kubernetes.NewForConfig")
+		leaseLog.Error(err, "Unable to build the hub clients", "hub", instance.Name, "errorClass", ErrorClass(err))
 		return nil, err
 	}
-	leaseLog.V(2).Info("kubernetes.NewForConfig succeeded")
-	return &leaseUpdater{
-		hubClient:         clientset,
-		name:              r.LeaseName,
-		namespace:         r.LeaseNamespace,
-		checkPodIsRunning: r.checkPodIsRunning,
-	}, nil
-}
-
-func BuildKubeClientWithSecret(secret *corev1.Secret) (kubernetes.Interface, error) {
-	tempdir, err := ioutil.TempDir("", "kube")
+	leaseLog.V(2).Info("Hub clients built", "hub", instance.Name, "servers", hubServers(endpoints))
+	namespace, err := r.leaseNamespace(ctx, instance, endpoints[0].Client)
 	if err != nil {
+		leaseLog.Error(err, "Unable to resolve the lease namespace", "hub", instance.Name)
 		return nil, err
 	}
-	defer os.RemoveAll(tempdir)
+	u = &leaseUpdater{
+		hub:                  instance.Name,
+		hubClient:            endpoints[0].Client,
+		endpoints:            endpoints,
+		name:                 r.LeaseName,
+		namespace:            namespace,
+		renewInterval:        r.RenewInterval,
+		checkPodIsRunning:    r.checkPodIsRunning,
+		leaseDurationSeconds: r.LeaseDurationSeconds,
+		labels:               r.LeaseLabels,
+		annotations:          r.LeaseAnnotations,
+		owner:                r.OwnerAddOnName,
+		getOwnerReference:    r.GetOwnerReferenceFunc,
+		holderIdentity:       r.HolderIdentity,
+		clockSkewWarning:     r.ClockSkewWarning,
+		correctClockSkew:     r.CorrectClockSkew,
+		verifyInterval:       r.VerifyRenewInterval,
+		recordEvent: func(eventType, reason, message string) {
+			r.recordEvent(instance, eventType, reason, message)
+		},
+	}
+	if u.getOwnerReference == nil {
+		u.getOwnerReference = GetManagedClusterAddOnOwnerReference
+	}
+	if r.SkipPodReadyCheck {
+		u.checkPodIsRunning = nil
+	} else if r.PodStatus != nil {
+		u.onPodReady = r.PodStatus.OnReady
+	}
+	return u, nil
+}
 
-	for key, data := range secret.Data {
-		if err := ioutil.WriteFile(path.Join(tempdir, key), data, 0600); err != nil {
-			return nil, err
+// buildHubEndpoints returns the hub API servers defined by the secret, the preferred one first
+func (r *LeaseReconciler) buildHubEndpoints(instance *corev1.Secret) ([]HubEndpoint, error) {
+	if r.BuildHubEndpointsWithSecretFunc != nil {
+		endpoints, err := r.BuildHubEndpointsWithSecretFunc(instance)
+		if err == nil && len(endpoints) == 0 {
+			err = fmt.Errorf("no hub server found in secret %s/%s", instance.Namespace, instance.Name)
 		}
+		return endpoints, err
 	}
-	restConfig, err := clientcmd.BuildConfigFromFlags("", path.Join(tempdir, "kubeconfig"))
+	clientset, err := r.BuildKubeClientWithSecretFunc(instance)
 	if err != nil {
 		return nil, err
 	}
-	return kubernetes.NewForConfig(restConfig)
+	return []HubEndpoint{{Client: clientset}}, nil
 }
 
 // start a lease update routine to update the lease of a managed cluster periodically.
-func (u *leaseUpdater) start(ctx context.Context, leaseDurationSeconds *int32) error {
+func (u *leaseUpdater) start(ctx context.Context, leaseDurationSeconds *int32) (err error) {
 	u.lock.Lock()
 	defer u.lock.Unlock()
-	_, err := u.hubClient.CoordinationV1().Leases(u.namespace).Get(context.TODO(), u.name, metav1.GetOptions{})
-	if err != nil {
-		if errors.IsNotFound(err) {
-			leaseLog.Info(fmt.Sprintf("start lease for %s/%s", u.name, u.namespace))
-			lease := &coordinationv1.Lease{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      u.name,
-					Namespace: u.namespace,
-				},
-				Spec: coordinationv1.LeaseSpec{
-					LeaseDurationSeconds: leaseDurationSeconds,
-				},
-			}
-			if _, err := u.hubClient.CoordinationV1().Leases(u.namespace).Create(ctx, lease, metav1.CreateOptions{}); err != nil {
-				leaseLog.Error(err, fmt.Sprintf("unable to create addon lease %q/%q on hub cluster", u.name, u.namespace))
-				return err
-			}
-		} else {
-			return err
-		}
+	spanCtx, span := tracer().Start(ctx, "leaseUpdater.start", u.spanAttributes())
+	defer func() { endSpan(span, err) }()
+	if err := u.ensureLease(spanCtx, leaseDurationSeconds); err != nil {
+		return err
 	}
 
 	var updateCtx context.Context
 
-	updateCtx, u.cancel = context.WithCancel(ctx)
-	d := time.Duration(*leaseDurationSeconds) * time.Second
+	// each renewal is traced on its own, not as a child of the span starting the updater
+	updateCtx, u.cancel = context.WithCancel(trace.ContextWithSpanContext(ctx, trace.SpanContext{}))
+	d := u.renewInterval
+	if d <= 0 {
+		d = time.Duration(*leaseDurationSeconds) * time.Second
+	}
 	go wait.JitterUntilWithContext(updateCtx, u.update, d, -1, true)
-	leaseLog.V(2).Info(fmt.Sprintf("ManagedClusterLeaseUpdateStarted Start to update lease %q/%q on hub cluster", u.name, u.namespace))
+	if u.verifyInterval > 0 {
+		go wait.UntilWithContext(updateCtx, u.verify, u.verifyInterval)
+	}
+	if u.onPodReady != nil {
+		// renew as soon as the pod becomes ready rather than on the next tick
+		u.removeReadyHandler = u.onPodReady(func() {
+			go u.update(updateCtx)
+		})
+	}
+	u.reportActiveEndpoint()
+	u.log().V(2).Info("ManagedClusterLeaseUpdateStarted Start to update the lease", "renewInterval", d)
+	return nil
+}
+
+// ensureLease creates the lease on the hub if it does not exist, or syncs its spec with the desired one
+func (u *leaseUpdater) ensureLease(ctx context.Context, leaseDurationSeconds *int32) error {
+	if leaseDurationSeconds != nil {
+		u.leaseDurationSeconds = *leaseDurationSeconds
+	}
+	u.resolveOwner(ctx)
+	hubClient := u.getHubClient()
+	existing, err := hubClient.CoordinationV1().Leases(u.namespace).Get(ctx, u.name, metav1.GetOptions{})
+	if err == nil {
+		drift := u.syncLease(existing)
+		if len(drift) == 0 {
+			return nil
+		}
+		u.reportDrift(drift)
+		if _, err := hubClient.CoordinationV1().Leases(u.namespace).Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+			u.log().Error(err, "Unable to update the lease spec", "errorClass", ErrorClass(err))
+			return err
+		}
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+	u.log().Info("Creating the lease")
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      u.name,
+			Namespace: u.namespace,
+		},
+	}
+	u.syncLease(lease)
+	u.holdLease(lease, time.Now())
+	if _, err := hubClient.CoordinationV1().Leases(u.namespace).Create(ctx, lease, metav1.CreateOptions{}); err != nil {
+		u.log().Error(err, "Unable to create the lease", "errorClass", ErrorClass(err))
+		return err
+	}
 	return nil
 }
 
 // update the lease of a given managed cluster.
 func (u *leaseUpdater) update(ctx context.Context) {
+	u.updateLock.Lock()
+	defer u.updateLock.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	ctx, span := tracer().Start(ctx, "leaseUpdater.update", u.spanAttributes())
+	defer span.End()
 	if u.checkPodIsRunning != nil {
 		podIsRunning, err := u.checkPodIsRunning()
 		if err != nil {
-			leaseLog.Error(err, "unable to get pod status")
+			u.log().Error(err, "Unable to get the pod status", "errorClass", ErrorClass(err))
 			return
 		}
 		if !podIsRunning {
-			leaseLog.Info(fmt.Sprintf("Skipping lease %s/%s update as pod is not running.", u.name, u.namespace))
+			u.log().Info("Skipping the lease renewal as the pod is not running")
+			span.SetAttributes(attribute.Bool("skipped", true))
 			return
 		}
 	}
 
-	leaseLog.Info(fmt.Sprintf("Update lease %s/%s", u.name, u.namespace))
-	lease, err := u.hubClient.CoordinationV1().Leases(u.namespace).Get(ctx, u.name, metav1.GetOptions{})
+	if err := u.renew(ctx); err != nil {
+		recordSpanError(span, err)
+		u.reportRenew(false)
+		if isEndpointError(err) {
+			u.failover(ctx)
+		}
+		return
+	}
+	u.reportRenew(true)
+}
+
+// renew sets the renew time of the lease on the hub
+func (u *leaseUpdater) renew(ctx context.Context) error {
+	log, start := u.log(), time.Now()
+	hubClient := u.getHubClient()
+	lease, err := hubClient.CoordinationV1().Leases(u.namespace).Get(ctx, u.name, metav1.GetOptions{})
 	if err != nil {
 		// u.recorder.Eventf("unable to get cluster lease %q/%q on hub cluster %w", u.name, u.namespace, err)
-		leaseLog.Error(err, fmt.Sprintf("unable to get cluster lease %q/%q on hub cluster", u.name, u.namespace))
-		return
+		log.Error(err, "Lease renewal failed, unable to get the lease", "errorClass", ErrorClass(err), "duration", time.Since(start))
+		return err
 	}
 
-	lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now()}
-	if _, err = u.hubClient.CoordinationV1().Leases(u.namespace).Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+	u.reportDrift(u.syncLease(lease))
+	now := u.hubTime()
+	u.holdLease(lease, now)
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: now}
+	updated, err := hubClient.CoordinationV1().Leases(u.namespace).Update(ctx, lease, metav1.UpdateOptions{})
+	if err != nil {
 		// u.recorder.Eventf("unable to update addon lease %q/%q on hub cluster %w", u.name, u.namespace, err)
-		leaseLog.Error(err, fmt.Sprintf("unable to update cluster lease %q/%q on hub cluster", u.name, u.namespace))
-		return
+		log.Error(err, "Lease renewal failed, unable to update the lease", "errorClass", ErrorClass(err), "duration", time.Since(start))
+		return err
+	}
+	u.renewed = updated.Spec.RenewTime
+	log.Info("Lease renewed", "duration", time.Since(start))
+	return nil
+}
+
+// reportRenew exposes the result of a lease renewal on the hub
+func (u *leaseUpdater) reportRenew(succeeded bool) {
+	result, up := "failure", 0.0
+	if succeeded {
+		result, up = "success", 1
+	}
+	leaseRenewTotal.WithLabelValues(u.hub, u.namespace, u.name, result).Inc()
+	hubUp.WithLabelValues(u.hub, u.namespace, u.name).Set(up)
+}
+
+// getHubClient returns the client of the active hub endpoint
+func (u *leaseUpdater) getHubClient() kubernetes.Interface {
+	u.clientLock.RLock()
+	defer u.clientLock.RUnlock()
+	return u.hubClient
+}
+
+// failover switches to the next hub endpoint able to get the lease,
+// it returns false if none of the other endpoints works.
+func (u *leaseUpdater) failover(ctx context.Context) bool {
+	u.clientLock.Lock()
+	defer u.clientLock.Unlock()
+	for i := 1; i < len(u.endpoints); i++ {
+		next := (u.activeEndpoint + i) % len(u.endpoints)
+		endpoint := u.endpoints[next]
+		if err := checkLeaseClient(ctx, endpoint.Client, u.namespace, u.name); err != nil {
+			u.log().Error(err, "Hub server can not be used", "server", endpoint.Server, "errorClass", ErrorClass(err))
+			continue
+		}
+		u.log().Info("Failover to another hub server", "from", u.endpoints[u.activeEndpoint].Server, "server", endpoint.Server)
+		u.activeEndpoint = next
+		u.hubClient = endpoint.Client
+		u.reportActiveEndpointLocked()
+		return true
+	}
+	return false
+}
+
+// reportActiveEndpoint exposes which hub endpoint is used to renew the lease
+func (u *leaseUpdater) reportActiveEndpoint() {
+	u.clientLock.RLock()
+	defer u.clientLock.RUnlock()
+	u.reportActiveEndpointLocked()
+}
+
+func (u *leaseUpdater) reportActiveEndpointLocked() {
+	for i, endpoint := range u.endpoints {
+		active := 0.0
+		if i == u.activeEndpoint {
+			active = 1
+		}
+		hubEndpointActive.WithLabelValues(u.hub, u.namespace, u.name, endpoint.Server).Set(active)
 	}
 }
 
+// isEndpointError returns true if the error may be solved by using another hub API server
+func isEndpointError(err error) bool {
+	return !errors.IsNotFound(err) && !errors.IsConflict(err)
+}
+
 // stop the lease update routine.
 func (u *leaseUpdater) stop(ctx context.Context) {
 	u.lock.Lock()
 	defer u.lock.Unlock()
-	leaseLog.Info(fmt.Sprintf("stop: Stop to update lease %q/%q on hub cluster", u.name, u.namespace))
+	u.log().Info("Stopping the lease renewals")
 
+	if u.removeReadyHandler != nil {
+		u.removeReadyHandler()
+		u.removeReadyHandler = nil
+	}
 	if u.cancel == nil {
 		return
 	}
@@ -332,15 +715,26 @@ func (u *leaseUpdater) stop(ctx context.Context) {
 }
 
 // CheckLeaseUpdaterClient checks if the current client still functioning properly
-func CheckLeaseUpdaterClient(u *leaseUpdater) bool {
+func CheckLeaseUpdaterClient(ctx context.Context, u *leaseUpdater) bool {
 	if u == nil {
 		return false
 	}
-	leaseLog.Info(fmt.Sprintf("check if client can get lease %s/%s", u.name, u.namespace))
-	_, err := u.hubClient.CoordinationV1().Leases(u.namespace).Get(context.TODO(), u.name, metav1.GetOptions{})
-	if err != nil && !errors.IsNotFound(err) {
-		leaseLog.Error(err, fmt.Sprintf("failed to get lease %s/%s", u.name, u.namespace))
+	ctx, span := tracer().Start(ctx, "CheckLeaseUpdaterClient", u.spanAttributes())
+	u.log().Info("Checking the client can get the lease")
+	err := checkLeaseClient(ctx, u.getHubClient(), u.namespace, u.name)
+	endSpan(span, err)
+	if err != nil {
+		u.log().Error(err, "Failed to get the lease", "errorClass", ErrorClass(err))
 		return false
 	}
 	return true
 }
+
+// checkLeaseClient checks if the client can get the lease, a missing lease is not an error
+func checkLeaseClient(ctx context.Context, hubClient kubernetes.Interface, namespace, name string) error {
+	_, err := hubClient.CoordinationV1().Leases(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
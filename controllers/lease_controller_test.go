@@ -19,6 +19,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	fakekubeclient "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -116,7 +117,7 @@ func TestLeaseReconciler_checkPodIsRunning(t *testing.T) {
 				LeaseDurationSeconds: tt.fields.LeaseDurationSeconds,
 				PodName:              tt.fields.PodName,
 				PodNamespace:         tt.fields.PodNamespace,
-				leaseUpdater:         tt.fields.leaseUpdater,
+				hubLeases:            map[string]*hubLease{tt.fields.HubConfigSecretName: {leaseUpdater: tt.fields.leaseUpdater}},
 			}
 			got, err := r.checkPodIsRunning()
 			if (err != nil) != tt.wantErr {
@@ -241,9 +242,9 @@ users:
 				LeaseDurationSeconds:          tt.fields.LeaseDurationSeconds,
 				PodName:                       tt.fields.PodName,
 				PodNamespace:                  tt.fields.PodNamespace,
-				leaseUpdater:                  tt.fields.leaseUpdater,
+				hubLeases:                     map[string]*hubLease{tt.fields.HubConfigSecretName: {leaseUpdater: tt.fields.leaseUpdater}},
 			}
-			got, err := r.newUpdaterLease(tt.args.instance)
+			got, err := r.newUpdaterLease(context.TODO(), tt.args.instance)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("LeaseReconciler.newUpdaterLease() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -543,7 +544,7 @@ func TestLeaseReconciler_Reconcile(t *testing.T) {
 				HubConfigSecretName:       "fakesecretname",
 				LeaseDurationSeconds:      1,
 				BuildKubeClientWithSecret: fakeBuikdBuildKubeClientWithSecret,
-				CheckLeaseUpdaterClient:   func(u *leaseUpdater) bool { return false },
+				CheckLeaseUpdaterClient:   func(ctx context.Context, u *leaseUpdater) bool { return false },
 				cachedSecret:              secret1,
 				leaseUpdater:              &leaseUpdater{},
 				PodName:                   podName,
@@ -563,7 +564,7 @@ func TestLeaseReconciler_Reconcile(t *testing.T) {
 				HubConfigSecretName:       "fakesecretname",
 				LeaseDurationSeconds:      1,
 				BuildKubeClientWithSecret: fakeBuikdBuildKubeClientWithSecret,
-				CheckLeaseUpdaterClient:   func(u *leaseUpdater) bool { return u.name != "" },
+				CheckLeaseUpdaterClient:   func(ctx context.Context, u *leaseUpdater) bool { return u.name != "" },
 				cachedSecret:              secret1,
 				leaseUpdater:              &leaseUpdater{},
 				PodName:                   podName,
@@ -648,11 +649,13 @@ func TestLeaseReconciler_Reconcile(t *testing.T) {
 				HubConfigSecretName:           tt.fields.HubConfigSecretName,
 				BuildKubeClientWithSecretFunc: tt.fields.BuildKubeClientWithSecret,
 				CheckLeaseUpdaterClient:       tt.fields.CheckLeaseUpdaterClient,
-				cachedSecret:                  tt.fields.cachedSecret,
 				LeaseDurationSeconds:          tt.fields.LeaseDurationSeconds,
 				PodName:                       tt.fields.PodName,
 				PodNamespace:                  tt.fields.PodNamespace,
-				leaseUpdater:                  tt.fields.leaseUpdater,
+				hubLeases: map[string]*hubLease{tt.args.req.Name: {
+					leaseUpdater: tt.fields.leaseUpdater,
+					cachedSecret: tt.fields.cachedSecret,
+				}},
 			}
 			got, err := r.Reconcile(tt.args.req)
 			if (err != nil) != tt.wantErr {
@@ -733,7 +736,7 @@ func Test_CheckLeaseUpdaterClient(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			b := CheckLeaseUpdaterClient(tt.arg)
+			b := CheckLeaseUpdaterClient(context.TODO(), tt.arg)
 			if b != tt.want {
 				t.Errorf("CheckLeaseUpdaterClient() got = %v, want = %v", b, tt.want)
 				return
@@ -741,3 +744,138 @@ func Test_CheckLeaseUpdaterClient(t *testing.T) {
 		})
 	}
 }
+
+func Test_leaseUpdater_failover(t *testing.T) {
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-lease-name",
+			Namespace: "test-lease-namespace",
+		},
+	}
+	cFound := fakekubeclient.NewSimpleClientset(lease)
+	cUnAuth := fakekubeclient.NewSimpleClientset(lease)
+	cUnAuth.PrependReactor("*", "*", unAuth)
+	cX509 := fakekubeclient.NewSimpleClientset(lease)
+	cX509.PrependReactor("*", "*", x509)
+	tests := []struct {
+		name       string
+		endpoints  []HubEndpoint
+		active     int
+		want       bool
+		wantActive int
+	}{
+		{
+			name: "next endpoint works",
+			endpoints: []HubEndpoint{
+				{Server: "https://a", Client: cX509},
+				{Server: "https://b", Client: cFound},
+			},
+			active:     0,
+			want:       true,
+			wantActive: 1,
+		},
+		{
+			name: "skip broken endpoints and wrap around",
+			endpoints: []HubEndpoint{
+				{Server: "https://a", Client: cFound},
+				{Server: "https://b", Client: cUnAuth},
+				{Server: "https://c", Client: cX509},
+			},
+			active:     1,
+			want:       true,
+			wantActive: 0,
+		},
+		{
+			name: "no other endpoint works",
+			endpoints: []HubEndpoint{
+				{Server: "https://a", Client: cFound},
+				{Server: "https://b", Client: cX509},
+			},
+			active:     0,
+			want:       false,
+			wantActive: 0,
+		},
+		{
+			name: "single endpoint",
+			endpoints: []HubEndpoint{
+				{Server: "https://a", Client: cFound},
+			},
+			active:     0,
+			want:       false,
+			wantActive: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &leaseUpdater{
+				hubClient:      tt.endpoints[tt.active].Client,
+				endpoints:      tt.endpoints,
+				activeEndpoint: tt.active,
+				namespace:      "test-lease-namespace",
+				name:           "test-lease-name",
+			}
+			if got := u.failover(context.TODO()); got != tt.want {
+				t.Errorf("leaseUpdater.failover() = %v, want %v", got, tt.want)
+			}
+			if u.activeEndpoint != tt.wantActive {
+				t.Errorf("leaseUpdater.activeEndpoint = %v, want %v", u.activeEndpoint, tt.wantActive)
+			}
+			if u.getHubClient() != tt.endpoints[tt.wantActive].Client {
+				t.Error("leaseUpdater.hubClient is not the client of the active endpoint")
+			}
+		})
+	}
+}
+
+func TestLeaseReconciler_Reconcile_multipleHubs(t *testing.T) {
+	s := scheme.Scheme
+	activeSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "active-hub",
+			Namespace: "addon-ns",
+		},
+	}
+	standbySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "standby-hub",
+			Namespace: "addon-ns",
+		},
+	}
+	hubClients := map[string]kubernetes.Interface{
+		"active-hub":  fakekubeclient.NewSimpleClientset(),
+		"standby-hub": fakekubeclient.NewSimpleClientset(),
+	}
+	r := &LeaseReconciler{
+		Client:                         fake.NewFakeClientWithScheme(s, activeSecret, standbySecret),
+		Log:                            ctrl.Log.WithName("controllers").WithName("Lease"),
+		Scheme:                         s,
+		LeaseName:                      leaseName,
+		LeaseNamespace:                 leaseNamespace,
+		HubConfigSecretName:            "active-hub",
+		AdditionalHubConfigSecretNames: []string{"standby-hub"},
+		LeaseDurationSeconds:           1,
+		BuildKubeClientWithSecretFunc: func(secret *corev1.Secret) (kubernetes.Interface, error) {
+			return hubClients[secret.Name], nil
+		},
+	}
+	for _, name := range []string{"active-hub", "standby-hub", "other-secret"} {
+		if got := r.isHubConfigSecret(name); got != (name != "other-secret") {
+			t.Errorf("LeaseReconciler.isHubConfigSecret(%s) = %v", name, got)
+		}
+	}
+	for name, hubClient := range hubClients {
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "addon-ns", Name: name}}
+		if _, err := r.Reconcile(req); err != nil {
+			t.Errorf("LeaseReconciler.Reconcile() error = %v", err)
+		}
+		u := r.hubLeases[name].leaseUpdater
+		if u == nil || u.hub != name || u.getHubClient() != hubClient {
+			t.Errorf("LeaseReconciler.Reconcile() no lease updater for hub %s", name)
+			continue
+		}
+		defer u.stop(context.TODO())
+		if _, err := hubClient.CoordinationV1().Leases(leaseNamespace).Get(context.TODO(), leaseName, metav1.GetOptions{}); err != nil {
+			t.Errorf("Lease not found on hub %s: %v", name, err)
+		}
+	}
+}
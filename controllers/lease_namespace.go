@@ -0,0 +1,140 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/cert"
+)
+
+// hubIdentityPrefix is the prefix of the open-cluster-management hub identities
+const hubIdentityPrefix = "system:open-cluster-management:"
+
+// selfSubjectReviewVersions are the versions of the SelfSubjectReview API, the first one served is used
+var selfSubjectReviewVersions = []string{"v1", "v1beta1", "v1alpha1"}
+
+// IReviewSelf returns the username of the hub identity
+type IReviewSelf func(ctx context.Context, hubClient kubernetes.Interface) (string, error)
+
+// ReviewSelf returns the username of the hub identity with a SelfSubjectReview.
+// The SelfSubjectReview is created with a raw request as its API is not part of the kubernetes client.
+func ReviewSelf(ctx context.Context, hubClient kubernetes.Interface) (string, error) {
+	var err error
+	for _, version := range selfSubjectReviewVersions {
+		body := fmt.Sprintf(`{"apiVersion":"authentication.k8s.io/%s","kind":"SelfSubjectReview"}`, version)
+		var data []byte
+		data, err = hubClient.CoreV1().RESTClient().Post().
+			AbsPath("/apis/authentication.k8s.io", version, "selfsubjectreviews").
+			SetHeader("Content-Type", "application/json").
+			Body([]byte(body)).
+			Do(ctx).Raw()
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		review := struct {
+			Status struct {
+				UserInfo struct {
+					Username string `json:"username"`
+				} `json:"userInfo"`
+			} `json:"status"`
+		}{}
+		if err := json.Unmarshal(data, &review); err != nil {
+			return "", fmt.Errorf("unable to decode the SelfSubjectReview: %v", err)
+		}
+		return review.Status.UserInfo.Username, nil
+	}
+	return "", fmt.Errorf("the SelfSubjectReview API is not served: %v", err)
+}
+
+// ClusterNameFromUsername returns the managed cluster name encoded in an open-cluster-management hub identity,
+// system:open-cluster-management:cluster:<cluster>:addon:<addon>:agent:<agent> or system:open-cluster-management:<cluster>:<agent>,
+// empty if the username is not such an identity.
+func ClusterNameFromUsername(username string) string {
+	if !strings.HasPrefix(username, hubIdentityPrefix) {
+		return ""
+	}
+	parts := strings.Split(strings.TrimPrefix(username, hubIdentityPrefix), ":")
+	if len(parts) >= 4 && parts[0] == "cluster" && parts[1] != "" && parts[2] == "addon" {
+		return parts[1]
+	}
+	if len(parts) == 2 && parts[0] != "" {
+		return parts[0]
+	}
+	return ""
+}
+
+// leaseNamespace returns the namespace of the lease on the hub of the secret. When DeriveLeaseNamespace is set,
+// it is derived, in order, from the namespace of the kubeconfig context, the subject of the client certificate
+// and a SelfSubjectReview, the LeaseNamespace is the fallback. It fails if there is no namespace.
+func (r *LeaseReconciler) leaseNamespace(ctx context.Context, secret *corev1.Secret, hubClient kubernetes.Interface) (string, error) {
+	if !r.DeriveLeaseNamespace {
+		return r.LeaseNamespace, nil
+	}
+	namespace, source := r.deriveLeaseNamespace(ctx, secret, hubClient)
+	if namespace == "" {
+		if r.LeaseNamespace == "" {
+			return "", fmt.Errorf("unable to derive the lease namespace from the hub secret %s/%s and the lease-namespace is not set",
+				secret.Namespace, secret.Name)
+		}
+		leaseLog.Info("Unable to derive the lease namespace from the hub secret, using the lease-namespace",
+			"hub", secret.Name, "secret", secret.Namespace+"/"+secret.Name, "namespace", r.LeaseNamespace)
+		return r.LeaseNamespace, nil
+	}
+	leaseLog.V(2).Info("Lease namespace derived from the hub secret",
+		"hub", secret.Name, "secret", secret.Namespace+"/"+secret.Name, "namespace", namespace, "source", source)
+	return namespace, nil
+}
+
+// deriveLeaseNamespace returns the lease namespace derived from the hub secret and identity, and its source
+func (r *LeaseReconciler) deriveLeaseNamespace(ctx context.Context, secret *corev1.Secret, hubClient kubernetes.Interface) (string, string) {
+	config, err := clientcmd.Load(secret.Data[hubKubeConfigKey])
+	if err == nil {
+		if kubeContext, ok := config.Contexts[config.CurrentContext]; ok {
+			// the default namespace is not a cluster namespace, it is often set by the kubeconfig generators
+			if kubeContext.Namespace != "" && kubeContext.Namespace != metav1.NamespaceDefault {
+				return kubeContext.Namespace, "kubeconfig context"
+			}
+			if authInfo, ok := config.AuthInfos[kubeContext.AuthInfo]; ok {
+				data := secretFileData(authInfo.ClientCertificateData, authInfo.ClientCertificate, secret)
+				if certs, err := cert.ParseCertsPEM(data); err == nil && len(certs) > 0 {
+					if cluster := ClusterNameFromUsername(certs[0].Subject.CommonName); cluster != "" {
+						return cluster, "client certificate"
+					}
+				}
+			}
+		}
+	}
+
+	reviewSelf := r.ReviewSelfFunc
+	if reviewSelf == nil {
+		reviewSelf = ReviewSelf
+	}
+	username, err := reviewSelf(ctx, hubClient)
+	if err != nil {
+		leaseLog.Error(err, "Unable to review the hub identity",
+			"hub", secret.Name, "secret", secret.Namespace+"/"+secret.Name, "errorClass", ErrorClass(err))
+		return "", ""
+	}
+	return ClusterNameFromUsername(username), "SelfSubjectReview"
+}
+
+// secretFileData returns the inline data, or the content of the file taken from the secret key of the same name
+func secretFileData(data []byte, file string, secret *corev1.Secret) []byte {
+	if len(data) == 0 && file != "" {
+		return secret.Data[filepath.Base(file)]
+	}
+	return data
+}
@@ -0,0 +1,217 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/cert"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestClusterNameFromUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		want     string
+	}{
+		{username: "system:open-cluster-management:cluster:cluster1:addon:my-addon:agent:my-agent", want: "cluster1"},
+		{username: "system:open-cluster-management:cluster1:addon", want: "cluster1"},
+		{username: "system:open-cluster-management:cluster:my-agent", want: "cluster"},
+		{username: "system:open-cluster-management:cluster1", want: ""},
+		{username: "system:serviceaccount:cluster1:addon", want: ""},
+		{username: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			if got := ClusterNameFromUsername(tt.username); got != tt.want {
+				t.Errorf("ClusterNameFromUsername() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaseReconciler_leaseNamespace(t *testing.T) {
+	clientCert, _, err := cert.GenerateSelfSignedCertKey("system:open-cluster-management:cert-cluster:addon", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenKubeConfig := string(newTestKubeConfig("https://api.hub.com:6443", nil))
+	contextKubeConfig := strings.Replace(tokenKubeConfig, "    namespace: default", "    namespace: context-cluster", 1)
+	certKubeConfig := strings.Replace(tokenKubeConfig, "    token: fake", "    client-certificate: tls.crt", 1)
+	inlineCertKubeConfig := strings.Replace(tokenKubeConfig, "    token: fake",
+		"    client-certificate-data: "+base64.StdEncoding.EncodeToString(clientCert), 1)
+	reviewSelf := func(username string, err error) IReviewSelf {
+		return func(ctx context.Context, hubClient kubernetes.Interface) (string, error) {
+			return username, err
+		}
+	}
+	tests := []struct {
+		name       string
+		derive     bool
+		secret     *corev1.Secret
+		reviewSelf IReviewSelf
+		noFallback bool
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:   "not derived",
+			derive: false,
+			secret: &corev1.Secret{Data: map[string][]byte{"kubeconfig": []byte(contextKubeConfig)}},
+			want:   "flag-namespace",
+		},
+		{
+			name:   "kubeconfig context",
+			derive: true,
+			secret: &corev1.Secret{Data: map[string][]byte{"kubeconfig": []byte(contextKubeConfig)}},
+			want:   "context-cluster",
+		},
+		{
+			name:   "client certificate file",
+			derive: true,
+			secret: &corev1.Secret{Data: map[string][]byte{
+				"kubeconfig": []byte(certKubeConfig),
+				"tls.crt":    clientCert,
+			}},
+			want: "cert-cluster",
+		},
+		{
+			name:   "inline client certificate",
+			derive: true,
+			secret: &corev1.Secret{Data: map[string][]byte{"kubeconfig": []byte(inlineCertKubeConfig)}},
+			want:   "cert-cluster",
+		},
+		{
+			name:       "SelfSubjectReview",
+			derive:     true,
+			secret:     &corev1.Secret{Data: map[string][]byte{"kubeconfig": []byte(tokenKubeConfig)}},
+			reviewSelf: reviewSelf("system:open-cluster-management:cluster:review-cluster:addon:a:agent:b", nil),
+			want:       "review-cluster",
+		},
+		{
+			name:       "fallback unknown identity",
+			derive:     true,
+			secret:     &corev1.Secret{Data: map[string][]byte{"kubeconfig": []byte(tokenKubeConfig)}},
+			reviewSelf: reviewSelf("system:serviceaccount:ns:sa", nil),
+			want:       "flag-namespace",
+		},
+		{
+			name:       "no fallback",
+			derive:     true,
+			secret:     &corev1.Secret{Data: map[string][]byte{"kubeconfig": []byte(tokenKubeConfig)}},
+			reviewSelf: reviewSelf("system:serviceaccount:ns:sa", nil),
+			noFallback: true,
+			wantErr:    true,
+		},
+		{
+			name:       "fallback review error",
+			derive:     true,
+			secret:     &corev1.Secret{Data: map[string][]byte{"kubeconfig": []byte(tokenKubeConfig)}},
+			reviewSelf: reviewSelf("", fmt.Errorf("forbidden")),
+			want:       "flag-namespace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &LeaseReconciler{
+				LeaseNamespace:       "flag-namespace",
+				DeriveLeaseNamespace: tt.derive,
+				ReviewSelfFunc:       tt.reviewSelf,
+			}
+			if r.ReviewSelfFunc == nil {
+				r.ReviewSelfFunc = reviewSelf("", fmt.Errorf("unexpected review"))
+			}
+			if tt.noFallback {
+				r.LeaseNamespace = ""
+			}
+			got, err := r.leaseNamespace(context.TODO(), tt.secret, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LeaseReconciler.leaseNamespace() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("LeaseReconciler.leaseNamespace() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaseReconciler_Reconcile_noLeaseNamespace(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "hub-secret",
+			Namespace: "addon-ns",
+		},
+		Data: map[string][]byte{
+			"kubeconfig": newTestKubeConfig("https://api.hub.com:6443", nil),
+		},
+	}
+	o := &HubClientOptions{}
+	r := &LeaseReconciler{
+		Client:                        fake.NewFakeClientWithScheme(scheme.Scheme, secret),
+		Log:                           ctrl.Log.WithName("controllers").WithName("Lease"),
+		LeaseName:                     leaseName,
+		HubConfigSecretName:           "hub-secret",
+		LeaseDurationSeconds:          1,
+		BuildKubeClientWithSecretFunc: o.BuildKubeClientWithSecret,
+		DeriveLeaseNamespace:          true,
+		ReviewSelfFunc: func(ctx context.Context, hubClient kubernetes.Interface) (string, error) {
+			return "", fmt.Errorf("forbidden")
+		},
+	}
+	if _, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "addon-ns", Name: "hub-secret"}}); err == nil {
+		t.Error("LeaseReconciler.Reconcile() must fail and requeue without lease namespace")
+	}
+	if r.hubLeases["hub-secret"].leaseUpdater != nil {
+		t.Error("LeaseReconciler.Reconcile() lease updater started without lease namespace")
+	}
+}
+
+func TestReviewSelf(t *testing.T) {
+	paths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		paths = append(paths, req.URL.Path)
+		if req.Method != http.MethodPost || req.URL.Path != "/apis/authentication.k8s.io/v1beta1/selfsubjectreviews" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+			return
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		if !strings.Contains(string(body), `"apiVersion":"authentication.k8s.io/v1beta1"`) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"apiVersion":"authentication.k8s.io/v1beta1","kind":"SelfSubjectReview",`+
+			`"status":{"userInfo":{"username":"system:open-cluster-management:cluster1:addon"}}}`)
+	}))
+	defer server.Close()
+	hubClient, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReviewSelf(context.TODO(), hubClient)
+	if err != nil {
+		t.Fatalf("ReviewSelf() error = %v", err)
+	}
+	if got != "system:open-cluster-management:cluster1:addon" {
+		t.Errorf("ReviewSelf() = %q", got)
+	}
+	if len(paths) != 2 {
+		t.Errorf("ReviewSelf() requests = %v, want v1 then v1beta1", paths)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"strings"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// syncLease sets the desired duration, labels, annotations and owner on the lease,
+// it returns the fields which differed. The labels and annotations not desired are kept.
+func (u *leaseUpdater) syncLease(lease *coordinationv1.Lease) []string {
+	drift := []string{}
+	if u.leaseDurationSeconds > 0 &&
+		(lease.Spec.LeaseDurationSeconds == nil || *lease.Spec.LeaseDurationSeconds != u.leaseDurationSeconds) {
+		d := u.leaseDurationSeconds
+		lease.Spec.LeaseDurationSeconds = &d
+		drift = append(drift, "leaseDurationSeconds")
+	}
+	for key, value := range u.labels {
+		if current, ok := lease.Labels[key]; !ok || current != value {
+			if lease.Labels == nil {
+				lease.Labels = map[string]string{}
+			}
+			lease.Labels[key] = value
+			drift = append(drift, "labels")
+		}
+	}
+	for key, value := range u.annotations {
+		if current, ok := lease.Annotations[key]; !ok || current != value {
+			if lease.Annotations == nil {
+				lease.Annotations = map[string]string{}
+			}
+			lease.Annotations[key] = value
+			drift = append(drift, "annotations")
+		}
+	}
+	if u.ownerReference != nil && !hasOwnerReference(lease, u.ownerReference) {
+		owners := []metav1.OwnerReference{}
+		for _, owner := range lease.OwnerReferences {
+			// an owner recreated on the hub has a new UID
+			if owner.APIVersion != u.ownerReference.APIVersion || owner.Kind != u.ownerReference.Kind || owner.Name != u.ownerReference.Name {
+				owners = append(owners, owner)
+			}
+		}
+		lease.OwnerReferences = append(owners, *u.ownerReference)
+		drift = append(drift, "ownerReferences")
+	}
+	return appendMissing(nil, drift...)
+}
+
+// reportDrift reports the fields of the hub lease which differed from the desired spec
+func (u *leaseUpdater) reportDrift(drift []string) {
+	if len(drift) == 0 {
+		return
+	}
+	u.log().Info("Lease differs from the desired spec, correcting it", "fields", strings.Join(drift, ","))
+	for _, field := range drift {
+		leaseDriftTotal.WithLabelValues(u.hub, u.namespace, u.name, field).Inc()
+	}
+}
+
+// hasOwnerReference returns true if the lease has the owner reference
+func hasOwnerReference(lease *coordinationv1.Lease, ref *metav1.OwnerReference) bool {
+	for _, owner := range lease.OwnerReferences {
+		if owner.UID == ref.UID && owner.Name == ref.Name && owner.Kind == ref.Kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLeaseUpdater_syncLease(t *testing.T) {
+	duration := func(d int32) *int32 { return &d }
+	tests := []struct {
+		name      string
+		lease     *coordinationv1.Lease
+		want      *coordinationv1.Lease
+		wantDrift []string
+	}{
+		{
+			name: "in sync",
+			lease: &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      map[string]string{"addon": "my-addon", "other": "kept"},
+					Annotations: map[string]string{"version": "2.3"},
+				},
+				Spec: coordinationv1.LeaseSpec{LeaseDurationSeconds: duration(60)},
+			},
+			want: &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      map[string]string{"addon": "my-addon", "other": "kept"},
+					Annotations: map[string]string{"version": "2.3"},
+				},
+				Spec: coordinationv1.LeaseSpec{LeaseDurationSeconds: duration(60)},
+			},
+			wantDrift: nil,
+		},
+		{
+			name:  "new lease",
+			lease: &coordinationv1.Lease{},
+			want: &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      map[string]string{"addon": "my-addon"},
+					Annotations: map[string]string{"version": "2.3"},
+				},
+				Spec: coordinationv1.LeaseSpec{LeaseDurationSeconds: duration(60)},
+			},
+			wantDrift: []string{"leaseDurationSeconds", "labels", "annotations"},
+		},
+		{
+			name: "drift",
+			lease: &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      map[string]string{"addon": "other-addon", "other": "kept"},
+					Annotations: map[string]string{"version": "2.3"},
+				},
+				Spec: coordinationv1.LeaseSpec{LeaseDurationSeconds: duration(30)},
+			},
+			want: &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      map[string]string{"addon": "my-addon", "other": "kept"},
+					Annotations: map[string]string{"version": "2.3"},
+				},
+				Spec: coordinationv1.LeaseSpec{LeaseDurationSeconds: duration(60)},
+			},
+			wantDrift: []string{"leaseDurationSeconds", "labels"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &leaseUpdater{
+				hub:                  "hub-secret",
+				name:                 leaseName,
+				namespace:            leaseNamespace,
+				leaseDurationSeconds: 60,
+				labels:               map[string]string{"addon": "my-addon"},
+				annotations:          map[string]string{"version": "2.3"},
+			}
+			if got := u.syncLease(tt.lease); !reflect.DeepEqual(got, tt.wantDrift) {
+				t.Errorf("leaseUpdater.syncLease() = %v, want %v", got, tt.wantDrift)
+			}
+			if !reflect.DeepEqual(tt.lease, tt.want) {
+				t.Errorf("leaseUpdater.syncLease() lease = %v, want %v", tt.lease, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaseUpdater_renew_drift(t *testing.T) {
+	d := int32(30)
+	hubClient := fakekubeclient.NewSimpleClientset(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      leaseName,
+			Namespace: leaseNamespace,
+			Labels:    map[string]string{"other": "kept"},
+		},
+		Spec: coordinationv1.LeaseSpec{LeaseDurationSeconds: &d},
+	})
+	u := &leaseUpdater{
+		hub:                  "hub-secret",
+		hubClient:            hubClient,
+		name:                 leaseName,
+		namespace:            leaseNamespace,
+		leaseDurationSeconds: 60,
+		labels:               map[string]string{"addon": "my-addon"},
+	}
+	if err := u.renew(context.TODO()); err != nil {
+		t.Fatalf("leaseUpdater.renew() error = %v", err)
+	}
+	lease, err := hubClient.CoordinationV1().Leases(leaseNamespace).Get(context.TODO(), leaseName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Spec.RenewTime == nil || *lease.Spec.LeaseDurationSeconds != 60 ||
+		!reflect.DeepEqual(lease.Labels, map[string]string{"other": "kept", "addon": "my-addon"}) {
+		t.Errorf("leaseUpdater.renew() lease = %v, want renewed with the desired spec", lease)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"net"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ErrorClassTimeout is the class of the errors of the requests timing out
+	ErrorClassTimeout = "Timeout"
+	// ErrorClassNetwork is the class of the errors of the requests not reaching the hub
+	ErrorClassNetwork = "Network"
+	// ErrorClassUnknown is the class of the other errors
+	ErrorClassUnknown = "Unknown"
+)
+
+// ErrorClass classifies an error for the logs: the reason of the API errors, such as Forbidden or NotFound,
+// Timeout, Network or Unknown
+func ErrorClass(err error) string {
+	if reason := errors.ReasonForError(err); reason != metav1.StatusReasonUnknown {
+		return string(reason)
+	}
+	if goerrors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+	var netErr net.Error
+	if goerrors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassNetwork
+	}
+	return ErrorClassUnknown
+}
+
+// log returns the logger of the lease updater, with the lease, its namespace and its hub
+func (u *leaseUpdater) log() logr.Logger {
+	return leaseLog.WithValues("lease", u.name, "namespace", u.namespace, "hub", u.hub)
+}
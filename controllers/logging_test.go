@@ -0,0 +1,103 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestErrorClass(t *testing.T) {
+	leases := schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "forbidden", err: errors.NewForbidden(leases, leaseName, fmt.Errorf("denied")), want: "Forbidden"},
+		{name: "not found", err: errors.NewNotFound(leases, leaseName), want: "NotFound"},
+		{name: "wrapped conflict", err: fmt.Errorf("renew: %w", errors.NewConflict(leases, leaseName, fmt.Errorf("changed"))), want: "Conflict"},
+		{name: "deadline", err: fmt.Errorf("renew: %w", context.DeadlineExceeded), want: ErrorClassTimeout},
+		{name: "network", err: &url.Error{Op: "Get", URL: "https://hub", Err: &net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}}, want: ErrorClassNetwork},
+		{name: "unknown", err: fmt.Errorf("unknown"), want: ErrorClassUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorClass(tt.err); got != tt.want {
+				t.Errorf("ErrorClass() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaseUpdater_renew_log(t *testing.T) {
+	tests := []struct {
+		name      string
+		forbidden bool
+		wantMsg   string
+		wantKeys  map[string]interface{}
+	}{
+		{
+			name:     "renewed",
+			wantMsg:  "Lease renewed",
+			wantKeys: map[string]interface{}{"lease": leaseName, "namespace": leaseNamespace, "hub": "hub-secret"},
+		},
+		{
+			name:      "forbidden",
+			forbidden: true,
+			wantMsg:   "Lease renewal failed, unable to update the lease",
+			wantKeys:  map[string]interface{}{"lease": leaseName, "namespace": leaseNamespace, "hub": "hub-secret", "errorClass": "Forbidden"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			previous := leaseLog
+			defer func() { leaseLog = previous }()
+			leaseLog = zap.New(zap.WriteTo(buf))
+			hubClient := fakekubeclient.NewSimpleClientset(&coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{Name: leaseName, Namespace: leaseNamespace},
+			})
+			if tt.forbidden {
+				hubClient.PrependReactor("update", "leases", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.NewForbidden(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, leaseName, fmt.Errorf("denied"))
+				})
+			}
+			u := &leaseUpdater{hub: "hub-secret", hubClient: hubClient, name: leaseName, namespace: leaseNamespace}
+			if err := u.renew(context.TODO()); (err != nil) != tt.forbidden {
+				t.Fatalf("leaseUpdater.renew() error = %v", err)
+			}
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			entry := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry); err != nil {
+				t.Fatalf("leaseUpdater.renew() log %q is not JSON: %v", buf.String(), err)
+			}
+			if entry["msg"] != tt.wantMsg {
+				t.Errorf("leaseUpdater.renew() log message = %v, want %s", entry["msg"], tt.wantMsg)
+			}
+			for key, value := range tt.wantKeys {
+				if entry[key] != value {
+					t.Errorf("leaseUpdater.renew() log %s = %v, want %v", key, entry[key], value)
+				}
+			}
+			if _, ok := entry["duration"]; !ok {
+				t.Errorf("leaseUpdater.renew() log %v has no duration", entry)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// hubEndpointActive reports which hub API server is used to renew the lease
+	hubEndpointActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "klusterlet_addon_lease_hub_endpoint_active",
+			Help: "Whether the hub API server is the one currently used to renew the lease (1) or not (0).",
+		},
+		[]string{"hub", "lease_namespace", "lease_name", "server"},
+	)
+
+	// leaseRenewTotal counts the lease renewals on each hub
+	leaseRenewTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "klusterlet_addon_lease_renew_total",
+			Help: "Number of lease renewals on the hub by result.",
+		},
+		[]string{"hub", "lease_namespace", "lease_name", "result"},
+	)
+
+	// hubUp reports if the last lease renewal on each hub succeeded
+	hubUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "klusterlet_addon_lease_hub_up",
+			Help: "Whether the last lease renewal on the hub succeeded (1) or not (0).",
+		},
+		[]string{"hub", "lease_namespace", "lease_name"},
+	)
+
+	// hubPolicyViolationsTotal counts the hub secrets rejected by the hub connection policy
+	hubPolicyViolationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "klusterlet_addon_lease_hub_policy_violations_total",
+			Help: "Number of times a hub secret was rejected by the hub connection policy, by reason.",
+		},
+		[]string{"hub", "reason"},
+	)
+
+	// podRestartsTotal counts the pod restarts triggered by a hub secret rotation
+	podRestartsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "klusterlet_addon_lease_pod_restarts_total",
+			Help: "Number of pod restarts triggered by a hub secret rotation, performed, suppressed by the restart budget or skipped by the None strategy.",
+		},
+		[]string{"strategy", "result"},
+	)
+
+	// leaseDriftTotal counts the hub lease fields found different from the desired spec and corrected
+	leaseDriftTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "klusterlet_addon_lease_spec_drift_total",
+			Help: "Number of times a field of the hub lease was found different from the desired spec and corrected, by field.",
+		},
+		[]string{"hub", "lease_namespace", "lease_name", "field"},
+	)
+
+	// hubPermissionMissing reports the permissions required on the hub and not allowed, checked before the lease updater starts
+	hubPermissionMissing = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "klusterlet_addon_lease_hub_permission_missing",
+			Help: "Whether a permission required on the hub is missing (1) or allowed (0), as found by the preflight.",
+		},
+		[]string{"hub", "permission"},
+	)
+
+	// hubClockSkewSeconds reports the estimated clock skew with the hub API server
+	hubClockSkewSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "klusterlet_addon_lease_hub_clock_skew_seconds",
+			Help: "Estimated clock skew with the hub API server, hub time minus local time, from the Date header of its responses.",
+		},
+		[]string{"hub", "server"},
+	)
+
+	// leaseVerificationTotal counts the verifications of the lease renewals on each hub
+	leaseVerificationTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "klusterlet_addon_lease_verification_total",
+			Help: "Number of verifications of the last lease renewal on the hub by result: verified, conflict, not_visible or error.",
+		},
+		[]string{"hub", "lease_namespace", "lease_name", "result"},
+	)
+
+	// leaseConflict reports if another agent renews the lease on each hub
+	leaseConflict = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "klusterlet_addon_lease_conflict",
+			Help: "Whether another agent renews or holds the lease on the hub (1) or not (0), as found by the last verification.",
+		},
+		[]string{"hub", "lease_namespace", "lease_name"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(hubEndpointActive, leaseRenewTotal, hubUp, hubPolicyViolationsTotal, podRestartsTotal, leaseDriftTotal, hubPermissionMissing, hubClockSkewSeconds,
+		leaseVerificationTotal, leaseConflict)
+}
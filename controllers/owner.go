@@ -0,0 +1,63 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ManagedClusterAddOnGroupVersion is the group version of the hub ManagedClusterAddOn owning the lease
+var ManagedClusterAddOnGroupVersion = schema.GroupVersion{Group: "addon.open-cluster-management.io", Version: "v1alpha1"}
+
+const (
+	// LabelAddonName is the label of the hub lease defining the addon name
+	LabelAddonName = "addon-lease.agent.stolostron.io/addon-name"
+	// LabelClusterName is the label of the hub lease defining the managed cluster name
+	LabelClusterName = "addon-lease.agent.stolostron.io/cluster-name"
+	// AnnotationControllerVersion is the annotation of the hub lease defining the version of the lease controller
+	AnnotationControllerVersion = "addon-lease.agent.stolostron.io/controller-version"
+)
+
+// IGetOwnerReference returns the owner reference of the hub object namespace/name
+type IGetOwnerReference func(ctx context.Context, hubClient kubernetes.Interface, namespace, name string) (*metav1.OwnerReference, error)
+
+// GetManagedClusterAddOnOwnerReference returns the owner reference of the hub ManagedClusterAddOn namespace/name.
+// The ManagedClusterAddOn is read with a raw request as its API is not part of the kubernetes client.
+func GetManagedClusterAddOnOwnerReference(ctx context.Context, hubClient kubernetes.Interface, namespace, name string) (*metav1.OwnerReference, error) {
+	data, err := hubClient.CoreV1().RESTClient().Get().
+		AbsPath("/apis", ManagedClusterAddOnGroupVersion.Group, ManagedClusterAddOnGroupVersion.Version,
+			"namespaces", namespace, "managedclusteraddons", name).
+		Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
+	addon := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(data, addon); err != nil {
+		return nil, fmt.Errorf("unable to decode the ManagedClusterAddOn %s/%s: %v", namespace, name, err)
+	}
+	return &metav1.OwnerReference{
+		APIVersion: ManagedClusterAddOnGroupVersion.String(),
+		Kind:       "ManagedClusterAddOn",
+		Name:       addon.Name,
+		UID:        addon.UID,
+	}, nil
+}
+
+// resolveOwner reads the owner reference of the lease once, the lease is created without owner if it fails
+func (u *leaseUpdater) resolveOwner(ctx context.Context) {
+	if u.getOwnerReference == nil || u.owner == "" || u.ownerReference != nil {
+		return
+	}
+	ref, err := u.getOwnerReference(ctx, u.getHubClient(), u.namespace, u.owner)
+	if err != nil {
+		u.log().Error(err, "Unable to get the owner of the lease, the lease has no owner", "owner", u.owner, "errorClass", ErrorClass(err))
+		return
+	}
+	u.ownerReference = ref
+}
@@ -0,0 +1,138 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func TestGetManagedClusterAddOnOwnerReference(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/apis/addon.open-cluster-management.io/v1alpha1/namespaces/cluster1/managedclusteraddons/my-addon" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"apiVersion":"addon.open-cluster-management.io/v1alpha1","kind":"ManagedClusterAddOn",`+
+			`"metadata":{"name":"my-addon","namespace":"cluster1","uid":"1234"}}`)
+	}))
+	defer server.Close()
+	hubClient, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetManagedClusterAddOnOwnerReference(context.TODO(), hubClient, "cluster1", "my-addon")
+	if err != nil {
+		t.Fatalf("GetManagedClusterAddOnOwnerReference() error = %v", err)
+	}
+	want := &metav1.OwnerReference{
+		APIVersion: "addon.open-cluster-management.io/v1alpha1",
+		Kind:       "ManagedClusterAddOn",
+		Name:       "my-addon",
+		UID:        types.UID("1234"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetManagedClusterAddOnOwnerReference() = %v, want %v", got, want)
+	}
+	if _, err := GetManagedClusterAddOnOwnerReference(context.TODO(), hubClient, "cluster1", "other-addon"); err == nil {
+		t.Error("GetManagedClusterAddOnOwnerReference() no error for a missing ManagedClusterAddOn")
+	}
+}
+
+func TestLeaseUpdater_ensureLease_owner(t *testing.T) {
+	owner := &metav1.OwnerReference{
+		APIVersion: "addon.open-cluster-management.io/v1alpha1",
+		Kind:       "ManagedClusterAddOn",
+		Name:       "my-addon",
+		UID:        types.UID("1234"),
+	}
+	tests := []struct {
+		name      string
+		getOwner  IGetOwnerReference
+		wantOwner []metav1.OwnerReference
+	}{
+		{
+			name: "owner found",
+			getOwner: func(ctx context.Context, hubClient kubernetes.Interface, namespace, name string) (*metav1.OwnerReference, error) {
+				return owner, nil
+			},
+			wantOwner: []metav1.OwnerReference{*owner},
+		},
+		{
+			name: "owner not found",
+			getOwner: func(ctx context.Context, hubClient kubernetes.Interface, namespace, name string) (*metav1.OwnerReference, error) {
+				return nil, fmt.Errorf("not found")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hubClient := fakekubeclient.NewSimpleClientset()
+			u := &leaseUpdater{
+				hub:               "hub-secret",
+				hubClient:         hubClient,
+				name:              leaseName,
+				namespace:         leaseNamespace,
+				owner:             "my-addon",
+				getOwnerReference: tt.getOwner,
+				labels:            map[string]string{LabelAddonName: "my-addon"},
+				annotations:       map[string]string{AnnotationControllerVersion: "2.3.0"},
+			}
+			d := int32(60)
+			if err := u.ensureLease(context.TODO(), &d); err != nil {
+				t.Fatalf("leaseUpdater.ensureLease() error = %v", err)
+			}
+			lease, err := hubClient.CoordinationV1().Leases(leaseNamespace).Get(context.TODO(), leaseName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(lease.OwnerReferences, tt.wantOwner) {
+				t.Errorf("leaseUpdater.ensureLease() owners = %v, want %v", lease.OwnerReferences, tt.wantOwner)
+			}
+			if lease.Labels[LabelAddonName] != "my-addon" || lease.Annotations[AnnotationControllerVersion] != "2.3.0" {
+				t.Errorf("leaseUpdater.ensureLease() labels = %v, annotations = %v", lease.Labels, lease.Annotations)
+			}
+		})
+	}
+}
+
+func TestLeaseUpdater_syncLease_owner(t *testing.T) {
+	owner := metav1.OwnerReference{
+		APIVersion: "addon.open-cluster-management.io/v1alpha1",
+		Kind:       "ManagedClusterAddOn",
+		Name:       "my-addon",
+		UID:        types.UID("new"),
+	}
+	other := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "other", UID: types.UID("other")}
+	stale := owner
+	stale.UID = types.UID("old")
+	u := &leaseUpdater{ownerReference: &owner}
+	lease := leaseWithOwners(other, stale)
+	if got := u.syncLease(lease); !reflect.DeepEqual(got, []string{"ownerReferences"}) {
+		t.Errorf("leaseUpdater.syncLease() = %v, want ownerReferences", got)
+	}
+	if !reflect.DeepEqual(lease.OwnerReferences, []metav1.OwnerReference{other, owner}) {
+		t.Errorf("leaseUpdater.syncLease() owners = %v", lease.OwnerReferences)
+	}
+	if got := u.syncLease(lease); len(got) != 0 {
+		t.Errorf("leaseUpdater.syncLease() = %v, want no drift", got)
+	}
+}
+
+func leaseWithOwners(owners ...metav1.OwnerReference) *coordinationv1.Lease {
+	return &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{OwnerReferences: owners}}
+}
@@ -0,0 +1,132 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PodStatusCache serves the readiness of the pod from an informer watching only this pod,
+// so the readiness changes are event driven and the API server is not polled.
+// It implements the manager Runnable interface.
+type PodStatusCache struct {
+	informer  cache.SharedIndexInformer
+	namespace string
+	name      string
+
+	lock          sync.Mutex
+	ready         bool
+	readyHandlers map[int]func()
+	nextHandlerID int
+}
+
+// NewPodStatusCache returns a cache of the status of the pod namespace/name
+func NewPodStatusCache(kubeClient kubernetes.Interface, namespace, name string, resync time.Duration) *PodStatusCache {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	informer := cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.FieldSelector = selector
+				return kubeClient.CoreV1().Pods(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.FieldSelector = selector
+				return kubeClient.CoreV1().Pods(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&corev1.Pod{},
+		resync,
+		cache.Indexers{},
+	)
+	c := &PodStatusCache{
+		informer:      informer,
+		namespace:     namespace,
+		name:          name,
+		readyHandlers: map[int]func(){},
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.setPod,
+		UpdateFunc: func(_, newObj interface{}) {
+			c.setPod(newObj)
+		},
+		DeleteFunc: func(interface{}) {
+			c.setReady(false)
+		},
+	})
+	return c
+}
+
+// Start runs the informer until stop is closed
+func (c *PodStatusCache) Start(stop <-chan struct{}) error {
+	leaseLog.Info("Start watching the pod status", "pod", c.name, "podNamespace", c.namespace)
+	c.informer.Run(stop)
+	return nil
+}
+
+// HasSynced returns true once the pod status is known
+func (c *PodStatusCache) HasSynced() bool {
+	return c.informer.HasSynced()
+}
+
+// IsReady checks if the pod is ready
+func (c *PodStatusCache) IsReady() (bool, error) {
+	if !c.HasSynced() {
+		return false, fmt.Errorf("status of pod %s/%s not synced yet", c.namespace, c.name)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.ready, nil
+}
+
+// OnReady registers a handler called each time the pod becomes ready, the returned function unregisters it
+func (c *PodStatusCache) OnReady(handler func()) (remove func()) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	id := c.nextHandlerID
+	c.nextHandlerID++
+	c.readyHandlers[id] = handler
+	return func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		delete(c.readyHandlers, id)
+	}
+}
+
+func (c *PodStatusCache) setPod(obj interface{}) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	c.setReady(isPodReady(pod))
+}
+
+// setReady updates the readiness and calls the ready handlers if the pod becomes ready
+func (c *PodStatusCache) setReady(ready bool) {
+	c.lock.Lock()
+	becomesReady := ready && !c.ready
+	c.ready = ready
+	handlers := make([]func(), 0, len(c.readyHandlers))
+	for _, handler := range c.readyHandlers {
+		handlers = append(handlers, handler)
+	}
+	c.lock.Unlock()
+
+	if !becomesReady {
+		return
+	}
+	leaseLog.Info("Pod is ready", "pod", c.name, "podNamespace", c.namespace)
+	for _, handler := range handlers {
+		handler()
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPodStatusCache(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: podNamespace},
+	}
+	kubeClient := fakekubeclient.NewSimpleClientset(pod)
+	c := NewPodStatusCache(kubeClient, podNamespace, podName, 0)
+
+	if _, err := c.IsReady(); err == nil {
+		t.Error("PodStatusCache.IsReady() must fail before the cache is synced")
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go c.Start(stop)
+	if !cache.WaitForCacheSync(stop, c.HasSynced) {
+		t.Fatal("PodStatusCache not synced")
+	}
+	if ready, err := c.IsReady(); err != nil || ready {
+		t.Errorf("PodStatusCache.IsReady() = %v, %v, want false", ready, err)
+	}
+
+	readyCalls := make(chan struct{}, 10)
+	c.OnReady(func() { readyCalls <- struct{}{} })
+	removed := make(chan struct{}, 10)
+	remove := c.OnReady(func() { removed <- struct{}{} })
+	remove()
+
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	if _, err := kubeClient.CoreV1().Pods(podNamespace).UpdateStatus(context.TODO(), pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-readyCalls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("PodStatusCache ready handler not called")
+	}
+	if ready, err := c.IsReady(); err != nil || !ready {
+		t.Errorf("PodStatusCache.IsReady() = %v, %v, want true", ready, err)
+	}
+	if len(removed) != 0 {
+		t.Error("PodStatusCache removed ready handler called")
+	}
+
+	// no call while the pod stays ready
+	pod.Labels = map[string]string{"updated": "true"}
+	if _, err := kubeClient.CoreV1().Pods(podNamespace).Update(context.TODO(), pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-readyCalls:
+		t.Error("PodStatusCache ready handler called while the pod stays ready")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
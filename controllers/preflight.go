@@ -0,0 +1,163 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ReasonMissingHubPermissions is the reason of the Event recorded when the hub identity lacks permissions on the lease
+const ReasonMissingHubPermissions = "MissingHubPermissions"
+
+// IPreflight returns the permissions not allowed to the hub identity
+type IPreflight func(ctx context.Context, hubClient kubernetes.Interface, permissions []authorizationv1.ResourceAttributes) ([]authorizationv1.ResourceAttributes, error)
+
+// ReviewAccess returns the permissions not allowed to the hub identity, with a SelfSubjectAccessReview per permission
+func ReviewAccess(ctx context.Context, hubClient kubernetes.Interface, permissions []authorizationv1.ResourceAttributes) ([]authorizationv1.ResourceAttributes, error) {
+	missing := []authorizationv1.ResourceAttributes{}
+	for i := range permissions {
+		review, err := hubClient.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &permissions[i]},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if !review.Status.Allowed {
+			missing = append(missing, permissions[i])
+		}
+	}
+	return missing, nil
+}
+
+// requiredPermissions returns the permissions required on the hub to renew the lease, and to create it if it does not exist
+func (u *leaseUpdater) requiredPermissions(leaseExists bool) []authorizationv1.ResourceAttributes {
+	verbs := []string{"get", "create", "update"}
+	if leaseExists {
+		verbs = []string{"get", "update"}
+	}
+	permissions := []authorizationv1.ResourceAttributes{}
+	for _, verb := range verbs {
+		permission := authorizationv1.ResourceAttributes{
+			Namespace: u.namespace,
+			Verb:      verb,
+			Group:     coordinationv1.GroupName,
+			Resource:  "leases",
+			Name:      u.name,
+		}
+		if verb == "create" {
+			// the name is not known by the authorizer on create
+			permission.Name = ""
+		}
+		permissions = append(permissions, permission)
+	}
+	if u.owner != "" {
+		permissions = append(permissions, u.ownerPermission())
+	}
+	return permissions
+}
+
+// ownerPermission returns the permission to get the ManagedClusterAddOn owning the lease. It is optional:
+// without it the lease is created without owner.
+func (u *leaseUpdater) ownerPermission() authorizationv1.ResourceAttributes {
+	return authorizationv1.ResourceAttributes{
+		Namespace: u.namespace,
+		Verb:      "get",
+		Group:     ManagedClusterAddOnGroupVersion.Group,
+		Resource:  "managedclusteraddons",
+		Name:      u.owner,
+	}
+}
+
+// formatPermission formats a permission as "verb group/resource namespace/name"
+func formatPermission(p authorizationv1.ResourceAttributes) string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource = p.Group + "/" + p.Resource
+	}
+	object := p.Namespace
+	if p.Name != "" {
+		object += "/" + p.Name
+	}
+	return fmt.Sprintf("%s %s %s", p.Verb, resource, object)
+}
+
+// missingPermissions returns the permissions required by the lease updater and not allowed on its hub,
+// nil if the preflight is disabled or fails. The result is reported by the metric.
+func (r *LeaseReconciler) missingPermissions(ctx context.Context, u *leaseUpdater) []string {
+	if r.PreflightFunc == nil {
+		return nil
+	}
+	// the create permission is only required if the lease does not exist, an unknown lease requires it
+	_, err := u.getHubClient().CoordinationV1().Leases(u.namespace).Get(ctx, u.name, metav1.GetOptions{})
+	leaseExists := err == nil
+	required := u.requiredPermissions(leaseExists)
+	missing, err := r.PreflightFunc(ctx, u.getHubClient(), required)
+	if err != nil {
+		// the preflight is best effort, the lease renewal reports the errors
+		u.log().Error(err, "Unable to review the permissions on the hub, the preflight is skipped", "errorClass", ErrorClass(err))
+		return nil
+	}
+	names := []string{}
+	for _, p := range missing {
+		names = append(names, formatPermission(p))
+	}
+	for _, p := range required {
+		value := 0.0
+		if containsString(names, formatPermission(p)) {
+			value = 1
+		}
+		hubPermissionMissing.WithLabelValues(u.hub, formatPermission(p)).Set(value)
+	}
+	if leaseExists {
+		// the create permission is no longer required once the lease exists
+		for _, p := range u.requiredPermissions(false) {
+			if p.Verb == "create" {
+				hubPermissionMissing.DeleteLabelValues(u.hub, formatPermission(p))
+			}
+		}
+	}
+	return names
+}
+
+// preflight checks the permissions of the lease updater on its hub before it starts,
+// the missing ones are logged and recorded as an Event on the hub secret. It returns false if permissions
+// required to renew the lease are missing, the missing owner permission is only a warning.
+func (r *LeaseReconciler) preflight(ctx context.Context, u *leaseUpdater, secret *corev1.Secret) bool {
+	missing := []string{}
+	for _, permission := range r.missingPermissions(ctx, u) {
+		if u.owner != "" && permission == formatPermission(u.ownerPermission()) {
+			message := fmt.Sprintf("the hub identity of secret %s/%s is not allowed to %s, the lease is created without owner",
+				secret.Namespace, secret.Name, permission)
+			u.log().Info("WARNING: the hub identity is missing the owner permission", "secret", secret.Namespace+"/"+secret.Name, "missing", permission)
+			r.recordEvent(secret, corev1.EventTypeWarning, ReasonMissingHubPermissions, message)
+			continue
+		}
+		missing = append(missing, permission)
+	}
+	if len(missing) == 0 {
+		return true
+	}
+	message := fmt.Sprintf("the hub identity of secret %s/%s is not allowed to %s",
+		secret.Namespace, secret.Name, strings.Join(missing, ", "))
+	u.log().Info("Preflight failed, the hub identity is missing permissions", "secret", secret.Namespace+"/"+secret.Name, "missing", strings.Join(missing, ","))
+	r.recordEvent(secret, corev1.EventTypeWarning, ReasonMissingHubPermissions, message)
+	return false
+}
+
+// containsString returns true if the list contains the item
+func containsString(list []string, item string) bool {
+	for _, existing := range list {
+		if existing == item {
+			return true
+		}
+	}
+	return false
+}
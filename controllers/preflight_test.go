@@ -0,0 +1,159 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newAccessReviewClient returns a hub client allowing only the verbs on the leases
+func newAccessReviewClient(allowedVerbs ...string) *fakekubeclient.Clientset {
+	hubClient := fakekubeclient.NewSimpleClientset()
+	hubClient.PrependReactor("create", "selfsubjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = attributes.Resource == "leases" && containsString(allowedVerbs, attributes.Verb)
+		return true, review, nil
+	})
+	return hubClient
+}
+
+// withLease adds the lease to the hub client
+func withLease(hubClient *fakekubeclient.Clientset) *fakekubeclient.Clientset {
+	_ = hubClient.Tracker().Add(&coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: leaseName, Namespace: leaseNamespace}})
+	return hubClient
+}
+
+func TestReviewAccess(t *testing.T) {
+	u := &leaseUpdater{namespace: "cluster1", name: "addon-lease", owner: "my-addon"}
+	missing, err := ReviewAccess(context.TODO(), newAccessReviewClient("get", "update"), u.requiredPermissions(false))
+	if err != nil {
+		t.Fatalf("ReviewAccess() error = %v", err)
+	}
+	got := []string{}
+	for _, p := range missing {
+		got = append(got, formatPermission(p))
+	}
+	want := []string{
+		"create coordination.k8s.io/leases cluster1",
+		"get addon.open-cluster-management.io/managedclusteraddons cluster1/my-addon",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReviewAccess() = %v, want %v", got, want)
+	}
+}
+
+func TestLeaseReconciler_Reconcile_preflight(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "hub-secret",
+			Namespace: "addon-ns",
+		},
+	}
+	tests := []struct {
+		name        string
+		hubClient   kubernetes.Interface
+		want        ctrl.Result
+		owner       string
+		wantStarted bool
+		wantMissing string
+	}{
+		{
+			name:        "allowed",
+			hubClient:   newAccessReviewClient("get", "create", "update"),
+			want:        ctrl.Result{},
+			wantStarted: true,
+		},
+		{
+			name:        "missing owner permission",
+			hubClient:   newAccessReviewClient("get", "create", "update"),
+			owner:       "my-addon",
+			want:        ctrl.Result{},
+			wantStarted: true,
+			wantMissing: "get addon.open-cluster-management.io/managedclusteraddons",
+		},
+		{
+			name:        "existing lease without create",
+			hubClient:   withLease(newAccessReviewClient("get", "update")),
+			want:        ctrl.Result{},
+			wantStarted: true,
+		},
+		{
+			name:        "missing create",
+			hubClient:   newAccessReviewClient("get", "update"),
+			want:        ctrl.Result{Requeue: true, RequeueAfter: 60 * time.Second},
+			wantStarted: false,
+			wantMissing: "create coordination.k8s.io/leases",
+		},
+		{
+			name:        "missing update",
+			hubClient:   newAccessReviewClient("get", "create"),
+			want:        ctrl.Result{Requeue: true, RequeueAfter: 60 * time.Second},
+			wantStarted: false,
+			wantMissing: "update coordination.k8s.io/leases",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &LeaseReconciler{
+				Client:               fake.NewFakeClientWithScheme(scheme.Scheme, secret),
+				Log:                  ctrl.Log.WithName("controllers").WithName("Lease"),
+				LeaseName:            leaseName,
+				LeaseNamespace:       leaseNamespace,
+				HubConfigSecretName:  "hub-secret",
+				LeaseDurationSeconds: 60,
+				BuildKubeClientWithSecretFunc: func(secret *corev1.Secret) (kubernetes.Interface, error) {
+					return tt.hubClient, nil
+				},
+				PreflightFunc:  ReviewAccess,
+				Recorder:       recorder,
+				OwnerAddOnName: tt.owner,
+				GetOwnerReferenceFunc: func(ctx context.Context, hubClient kubernetes.Interface, namespace, name string) (*metav1.OwnerReference, error) {
+					return nil, fmt.Errorf("forbidden")
+				},
+			}
+			got, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "addon-ns", Name: "hub-secret"}})
+			if err != nil || got != tt.want {
+				t.Errorf("LeaseReconciler.Reconcile() = %v, %v, want %v", got, err, tt.want)
+			}
+			u := r.hubLeases["hub-secret"].leaseUpdater
+			if (u != nil) != tt.wantStarted {
+				t.Fatalf("LeaseReconciler.Reconcile() lease updater started = %v, want %v", u != nil, tt.wantStarted)
+			}
+			if u != nil {
+				u.stop(context.TODO())
+				if tt.wantMissing == "" {
+					return
+				}
+			}
+			select {
+			case event := <-recorder.Events:
+				if !strings.Contains(event, ReasonMissingHubPermissions) || !strings.Contains(event, tt.wantMissing) {
+					t.Errorf("LeaseReconciler.Reconcile() event = %s, want reason %s for %s", event, ReasonMissingHubPermissions, tt.wantMissing)
+				}
+			default:
+				t.Error("LeaseReconciler.Reconcile() no event recorded")
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"reflect"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// RBACOptions is the running configuration the least-privilege RBAC is generated for
+type RBACOptions struct {
+	// Name is the name of the Roles and RoleBindings
+	Name string
+	// Namespace is the namespace of the pod
+	Namespace string
+	// ServiceAccount is the ServiceAccount of the pod, in Namespace
+	ServiceAccount string
+	// HubConfigSecretNames are the hub kubeconfig secrets, in HubConfigSecretNamespace
+	HubConfigSecretNames     []string
+	HubConfigSecretNamespace string
+	// WatchPod is true when the pod readiness is checked
+	WatchPod        bool
+	RestartStrategy RestartStrategy
+	// RestartBudgetConfigMap is the ConfigMap of the restart budget, empty if no budget
+	RestartBudgetConfigMap string
+	// CABundleConfigMap is the ConfigMap of the extra hub CAs, empty if none
+	CABundleConfigMap types.NamespacedName
+	// LeaderElectionID is the name of the leader election ConfigMap, empty if the leader election is disabled
+	LeaderElectionID string
+	// LeaderElectionNamespace is the namespace of the leader election ConfigMap, default Namespace
+	LeaderElectionNamespace string
+	// LeaseName and LeaseNamespace define the lease on the hub
+	LeaseName      string
+	LeaseNamespace string
+	// OwnerAddOnName is the hub ManagedClusterAddOn owning the lease, empty if none
+	OwnerAddOnName string
+}
+
+// ManagedClusterRBAC returns the Roles and RoleBindings required on the managed cluster,
+// one Role and RoleBinding per namespace accessed.
+func (o *RBACOptions) ManagedClusterRBAC() []runtime.Object {
+	rules := map[string][]rbacv1.PolicyRule{}
+	namespaces := []string{}
+	addRule := func(namespace string, rule rbacv1.PolicyRule) {
+		if _, ok := rules[namespace]; !ok {
+			namespaces = append(namespaces, namespace)
+		}
+		for _, existing := range rules[namespace] {
+			if reflect.DeepEqual(existing, rule) {
+				return
+			}
+		}
+		rules[namespace] = append(rules[namespace], rule)
+	}
+
+	secrets := []string{}
+	for _, name := range o.HubConfigSecretNames {
+		if name != "" {
+			secrets = append(secrets, name)
+		}
+	}
+	if len(secrets) != 0 {
+		addRule(o.HubConfigSecretNamespace, rbacv1.PolicyRule{
+			APIGroups:     []string{""},
+			Resources:     []string{"secrets"},
+			ResourceNames: secrets,
+			Verbs:         []string{"get", "list", "watch"},
+		})
+	}
+
+	podVerbs := []string{}
+	if o.WatchPod {
+		podVerbs = append(podVerbs, "get", "list", "watch")
+	}
+	switch o.RestartStrategy {
+	case RestartStrategyDeletePod, "":
+		podVerbs = appendMissing(podVerbs, "get", "delete")
+	case RestartStrategyAnnotatePod:
+		podVerbs = appendMissing(podVerbs, "get", "patch")
+	case RestartStrategyRolloutRestart:
+		podVerbs = appendMissing(podVerbs, "get")
+	}
+	if len(podVerbs) != 0 {
+		addRule(o.Namespace, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+			Verbs:     podVerbs,
+		})
+	}
+	if o.RestartStrategy == RestartStrategyRolloutRestart {
+		addRule(o.Namespace, rbacv1.PolicyRule{
+			APIGroups: []string{"apps"},
+			Resources: []string{"replicasets"},
+			Verbs:     []string{"get"},
+		})
+		addRule(o.Namespace, rbacv1.PolicyRule{
+			APIGroups: []string{"apps"},
+			Resources: []string{"deployments"},
+			Verbs:     []string{"get", "patch"},
+		})
+	}
+
+	if o.RestartStrategy != RestartStrategyNone && o.RestartBudgetConfigMap != "" {
+		addConfigMapRules(addRule, o.Namespace, o.RestartBudgetConfigMap)
+	}
+	if o.LeaderElectionID != "" {
+		namespace := o.LeaderElectionNamespace
+		if namespace == "" {
+			namespace = o.Namespace
+		}
+		addConfigMapRules(addRule, namespace, o.LeaderElectionID)
+		// the leader election records Events on the ConfigMap
+		addRule(namespace, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"events"},
+			Verbs:     []string{"create", "patch"},
+		})
+	}
+	if o.CABundleConfigMap.Name != "" {
+		addRule(o.CABundleConfigMap.Namespace, rbacv1.PolicyRule{
+			APIGroups:     []string{""},
+			Resources:     []string{"configmaps"},
+			ResourceNames: []string{o.CABundleConfigMap.Name},
+			Verbs:         []string{"get"},
+		})
+	}
+
+	// Events on the pod and the hub secrets
+	addRule(o.Namespace, rbacv1.PolicyRule{
+		APIGroups: []string{""},
+		Resources: []string{"events"},
+		Verbs:     []string{"create", "patch"},
+	})
+	if o.HubConfigSecretNamespace != o.Namespace && len(secrets) != 0 {
+		addRule(o.HubConfigSecretNamespace, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"events"},
+			Verbs:     []string{"create", "patch"},
+		})
+	}
+
+	objects := []runtime.Object{}
+	for _, namespace := range namespaces {
+		objects = append(objects,
+			&rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Name: o.Name, Namespace: namespace},
+				Rules:      rules[namespace],
+			},
+			&rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: o.Name, Namespace: namespace},
+				RoleRef: rbacv1.RoleRef{
+					APIGroup: rbacv1.GroupName,
+					Kind:     "Role",
+					Name:     o.Name,
+				},
+				Subjects: []rbacv1.Subject{
+					{Kind: rbacv1.ServiceAccountKind, Name: o.ServiceAccount, Namespace: o.Namespace},
+				},
+			},
+		)
+	}
+	return objects
+}
+
+// HubRBAC returns the Role required on the hub by the identity of the hub kubeconfig
+func (o *RBACOptions) HubRBAC() *rbacv1.Role {
+	role := &rbacv1.Role{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+		ObjectMeta: metav1.ObjectMeta{Name: o.Name, Namespace: o.LeaseNamespace},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups:     []string{"coordination.k8s.io"},
+				Resources:     []string{"leases"},
+				ResourceNames: []string{o.LeaseName},
+				Verbs:         []string{"get", "update"},
+			},
+			{
+				// create can not be restricted by resourceNames
+				APIGroups: []string{"coordination.k8s.io"},
+				Resources: []string{"leases"},
+				Verbs:     []string{"create"},
+			},
+		},
+	}
+	if o.OwnerAddOnName != "" {
+		role.Rules = append(role.Rules, rbacv1.PolicyRule{
+			APIGroups:     []string{ManagedClusterAddOnGroupVersion.Group},
+			Resources:     []string{"managedclusteraddons"},
+			ResourceNames: []string{o.OwnerAddOnName},
+			Verbs:         []string{"get"},
+		})
+	}
+	return role
+}
+
+// addConfigMapRules allows to read, create and update the ConfigMap name
+func addConfigMapRules(addRule func(string, rbacv1.PolicyRule), namespace, name string) {
+	addRule(namespace, rbacv1.PolicyRule{
+		APIGroups:     []string{""},
+		Resources:     []string{"configmaps"},
+		ResourceNames: []string{name},
+		Verbs:         []string{"get", "update"},
+	})
+	// create can not be restricted by resourceNames
+	addRule(namespace, rbacv1.PolicyRule{
+		APIGroups: []string{""},
+		Resources: []string{"configmaps"},
+		Verbs:     []string{"create"},
+	})
+}
+
+// appendMissing appends the items not already in the list
+func appendMissing(list []string, items ...string) []string {
+	for _, item := range items {
+		found := false
+		for _, existing := range list {
+			if existing == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, item)
+		}
+	}
+	return list
+}
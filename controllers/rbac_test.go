@@ -0,0 +1,113 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestRBACOptions_ManagedClusterRBAC(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   *RBACOptions
+		wantRules map[string][]rbacv1.PolicyRule
+	}{
+		{
+			name: "delete pod",
+			options: &RBACOptions{
+				Namespace:                "addon-ns",
+				HubConfigSecretNames:     []string{"hub-secret"},
+				HubConfigSecretNamespace: "addon-ns",
+				WatchPod:                 true,
+				RestartStrategy:          RestartStrategyDeletePod,
+			},
+			wantRules: map[string][]rbacv1.PolicyRule{
+				"addon-ns": {
+					{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"hub-secret"}, Verbs: []string{"get", "list", "watch"}},
+					{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list", "watch", "delete"}},
+					{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "patch"}},
+				},
+			},
+		},
+		{
+			name: "rollout restart with budget and CA bundle in another namespace",
+			options: &RBACOptions{
+				Namespace:                "addon-ns",
+				HubConfigSecretNames:     []string{"hub-secret", "standby-hub-secret"},
+				HubConfigSecretNamespace: "addon-ns",
+				RestartStrategy:          RestartStrategyRolloutRestart,
+				RestartBudgetConfigMap:   "lease-restart-budget",
+				CABundleConfigMap:        types.NamespacedName{Namespace: "ca-ns", Name: "ca-bundle"},
+			},
+			wantRules: map[string][]rbacv1.PolicyRule{
+				"addon-ns": {
+					{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"hub-secret", "standby-hub-secret"}, Verbs: []string{"get", "list", "watch"}},
+					{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+					{APIGroups: []string{"apps"}, Resources: []string{"replicasets"}, Verbs: []string{"get"}},
+					{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "patch"}},
+					{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"lease-restart-budget"}, Verbs: []string{"get", "update"}},
+					{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"create"}},
+					{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "patch"}},
+				},
+				"ca-ns": {
+					{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"ca-bundle"}, Verbs: []string{"get"}},
+				},
+			},
+		},
+		{
+			name: "no restart",
+			options: &RBACOptions{
+				Namespace:                "addon-ns",
+				HubConfigSecretNames:     []string{"hub-secret"},
+				HubConfigSecretNamespace: "secret-ns",
+				RestartStrategy:          RestartStrategyNone,
+				RestartBudgetConfigMap:   "lease-restart-budget",
+			},
+			wantRules: map[string][]rbacv1.PolicyRule{
+				"secret-ns": {
+					{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"hub-secret"}, Verbs: []string{"get", "list", "watch"}},
+					{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "patch"}},
+				},
+				"addon-ns": {
+					{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "patch"}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotRules := map[string][]rbacv1.PolicyRule{}
+			for _, object := range tt.options.ManagedClusterRBAC() {
+				switch o := object.(type) {
+				case *rbacv1.Role:
+					gotRules[o.Namespace] = o.Rules
+				case *rbacv1.RoleBinding:
+					if o.Subjects[0].Namespace != tt.options.Namespace {
+						t.Errorf("RBACOptions.ManagedClusterRBAC() subject namespace = %s, want %s", o.Subjects[0].Namespace, tt.options.Namespace)
+					}
+				}
+			}
+			if !reflect.DeepEqual(gotRules, tt.wantRules) {
+				t.Errorf("RBACOptions.ManagedClusterRBAC() rules = %v, want %v", gotRules, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestRBACOptions_HubRBAC(t *testing.T) {
+	role := (&RBACOptions{LeaseName: "addon-lease", LeaseNamespace: "cluster1"}).HubRBAC()
+	if role.Namespace != "cluster1" || !reflect.DeepEqual(role.Rules[0].ResourceNames, []string{"addon-lease"}) {
+		t.Errorf("RBACOptions.HubRBAC() = %v", role)
+	}
+	if len(role.Rules) != 2 {
+		t.Errorf("RBACOptions.HubRBAC() = %v, want no ManagedClusterAddOn rule", role.Rules)
+	}
+	role = (&RBACOptions{LeaseName: "addon-lease", LeaseNamespace: "cluster1", OwnerAddOnName: "my-addon"}).HubRBAC()
+	if len(role.Rules) != 3 || !reflect.DeepEqual(role.Rules[2].ResourceNames, []string{"my-addon"}) {
+		t.Errorf("RBACOptions.HubRBAC() = %v, want the ManagedClusterAddOn rule", role.Rules)
+	}
+}
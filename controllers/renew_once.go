@@ -0,0 +1,52 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ErrPodNotReady is returned by RenewOnce when the lease is not renewed because the pod is not ready
+var ErrPodNotReady = fmt.Errorf("pod is not ready")
+
+// RenewOnce renews the lease once on the hub of the secret, without a long-lived updater.
+// The lease is created if missing as the updater does, and the other hub API servers are tried
+// if the preferred one fails. ErrPodNotReady is returned if the pod is not ready, unless SkipPodReadyCheck is set.
+func (r *LeaseReconciler) RenewOnce(ctx context.Context, secret *corev1.Secret) (err error) {
+	ctx, span := tracer().Start(ctx, "RenewOnce", trace.WithAttributes(attribute.String("hub", secret.Name)))
+	defer func() { endSpan(span, err) }()
+	if !r.SkipPodReadyCheck {
+		ready, err := r.checkPodIsRunning()
+		if err != nil {
+			return err
+		}
+		if !ready {
+			return ErrPodNotReady
+		}
+	}
+
+	u, err := r.newUpdaterLease(ctx, secret)
+	if err != nil {
+		return err
+	}
+	if err := u.ensureLease(ctx, &r.LeaseDurationSeconds); err != nil {
+		if !isEndpointError(err) || !u.failover(ctx) {
+			return err
+		}
+		if err := u.ensureLease(ctx, &r.LeaseDurationSeconds); err != nil {
+			return err
+		}
+	}
+	if err := u.renew(ctx); err != nil {
+		if !isEndpointError(err) || !u.failover(ctx) {
+			return err
+		}
+		return u.renew(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLeaseReconciler_RenewOnce(t *testing.T) {
+	readyPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: podNamespace},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	notReadyPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: podNamespace},
+	}
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: "addon-lease", Namespace: "cluster1"},
+	}
+	down := func() kubernetes.Interface {
+		c := fakekubeclient.NewSimpleClientset()
+		c.PrependReactor("*", "leases", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, fmt.Errorf("connection refused")
+		})
+		return c
+	}
+	tests := []struct {
+		name              string
+		pod               *corev1.Pod
+		skipPodReadyCheck bool
+		endpoints         []kubernetes.Interface
+		wantErr           error
+		wantAnyErr        bool
+	}{
+		{
+			name:      "lease created and renewed",
+			pod:       readyPod,
+			endpoints: []kubernetes.Interface{fakekubeclient.NewSimpleClientset()},
+		},
+		{
+			name:      "lease renewed",
+			pod:       readyPod,
+			endpoints: []kubernetes.Interface{fakekubeclient.NewSimpleClientset(lease)},
+		},
+		{
+			name:      "pod not ready",
+			pod:       notReadyPod,
+			endpoints: []kubernetes.Interface{fakekubeclient.NewSimpleClientset(lease)},
+			wantErr:   ErrPodNotReady,
+		},
+		{
+			name:              "pod not ready with skip pod ready check",
+			pod:               notReadyPod,
+			skipPodReadyCheck: true,
+			endpoints:         []kubernetes.Interface{fakekubeclient.NewSimpleClientset(lease)},
+		},
+		{
+			name:      "failover",
+			pod:       readyPod,
+			endpoints: []kubernetes.Interface{down(), fakekubeclient.NewSimpleClientset(lease)},
+		},
+		{
+			name:       "hub down",
+			pod:        readyPod,
+			endpoints:  []kubernetes.Interface{down()},
+			wantAnyErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &LeaseReconciler{
+				Client:               fake.NewFakeClientWithScheme(scheme.Scheme, tt.pod),
+				LeaseName:            "addon-lease",
+				LeaseNamespace:       "cluster1",
+				LeaseDurationSeconds: 60,
+				PodName:              podName,
+				PodNamespace:         podNamespace,
+				SkipPodReadyCheck:    tt.skipPodReadyCheck,
+				BuildHubEndpointsWithSecretFunc: func(secret *corev1.Secret) ([]HubEndpoint, error) {
+					endpoints := []HubEndpoint{}
+					for i, c := range tt.endpoints {
+						endpoints = append(endpoints, HubEndpoint{Server: fmt.Sprintf("https://api%d.hub.com", i), Client: c})
+					}
+					return endpoints, nil
+				},
+			}
+			err := r.RenewOnce(context.TODO(), &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "hub-secret"}})
+			if tt.wantAnyErr {
+				if err == nil {
+					t.Error("LeaseReconciler.RenewOnce() no error")
+				}
+				return
+			}
+			if err != tt.wantErr {
+				t.Errorf("LeaseReconciler.RenewOnce() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			renewed, err := tt.endpoints[len(tt.endpoints)-1].CoordinationV1().Leases("cluster1").Get(context.TODO(), "addon-lease", metav1.GetOptions{})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if renewed.Spec.RenewTime == nil {
+				t.Error("LeaseReconciler.RenewOnce() lease not renewed")
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RestartStrategy defines how the pod is restarted to use a new hub secret
+type RestartStrategy string
+
+const (
+	// RestartStrategyDeletePod deletes the pod, it is the default
+	RestartStrategyDeletePod RestartStrategy = "DeletePod"
+	// RestartStrategyRolloutRestart annotates the pod template of the owning Deployment, as kubectl rollout restart does
+	RestartStrategyRolloutRestart RestartStrategy = "RolloutRestart"
+	// RestartStrategyAnnotatePod annotates the pod with a restart annotation for the restart container,
+	// the container is expected to watch it through the downward API and to restart itself
+	RestartStrategyAnnotatePod RestartStrategy = "AnnotatePod"
+	// RestartStrategyNone does not restart anything, only an Event is recorded
+	RestartStrategyNone RestartStrategy = "None"
+)
+
+const (
+	// rolloutRestartAnnotation is the pod template annotation set by kubectl rollout restart
+	rolloutRestartAnnotation = "kubectl.kubernetes.io/restartedAt"
+	// containerRestartAnnotationPrefix prefixes the container name in the restart annotation of the pod
+	containerRestartAnnotationPrefix = "addon-lease.agent.stolostron.io/restart-"
+	// ReasonHubSecretRotated is the reason of the Event recorded when the pod must restart to use a new hub secret
+	ReasonHubSecretRotated = "HubSecretRotated"
+	// ReasonRestartBudgetExhausted is the reason of the Event recorded when the restarts are suppressed
+	ReasonRestartBudgetExhausted = "RestartBudgetExhausted"
+	// restartBudgetRestartsKey is the key of the restart times in the restart budget ConfigMap
+	restartBudgetRestartsKey = "restarts"
+	// restartBudgetExhaustedKey is set in the restart budget ConfigMap once the exhausted budget is reported
+	restartBudgetExhaustedKey = "exhausted"
+)
+
+// RestartBudget limits the automatic restarts to MaxRestarts per Window.
+// The restart times are persisted in a ConfigMap of the pod namespace so they survive the restarts.
+type RestartBudget struct {
+	// MaxRestarts is the maximum number of restarts within the window, 0 for no limit
+	MaxRestarts int
+	Window      time.Duration
+	// ConfigMapName is the name of the ConfigMap persisting the restart times
+	ConfigMapName string
+}
+
+// restartsPod returns true if the strategy replaces the pod, the new pod then uses the new hub secret
+func (s RestartStrategy) restartsPod() bool {
+	return s == "" || s == RestartStrategyDeletePod || s == RestartStrategyRolloutRestart
+}
+
+// ContainerRestartAnnotation returns the restart annotation of the pod for the restart container
+func ContainerRestartAnnotation(container string) string {
+	return containerRestartAnnotationPrefix + container
+}
+
+// ParseRestartStrategy parses a restart strategy, an empty strategy is RestartStrategyDeletePod
+func ParseRestartStrategy(strategy string) (RestartStrategy, error) {
+	switch s := RestartStrategy(strategy); s {
+	case "":
+		return RestartStrategyDeletePod, nil
+	case RestartStrategyDeletePod, RestartStrategyRolloutRestart, RestartStrategyAnnotatePod, RestartStrategyNone:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown restart strategy %q", strategy)
+}
+
+// restartPod restarts the pod using the restart strategy so the new hub secret is used.
+// If the restart budget is exhausted, the restart is suppressed and the time to wait for the next restart is returned.
+func (r *LeaseReconciler) restartPod() (time.Duration, error) {
+	pod := &corev1.Pod{}
+	if err := r.reader().Get(context.TODO(),
+		types.NamespacedName{Name: r.PodName, Namespace: r.PodNamespace},
+		pod,
+	); err != nil {
+		leaseLog.Error(err, "Failed to get the pod", "pod", r.PodName, "podNamespace", r.PodNamespace, "errorClass", ErrorClass(err))
+		return 0, err
+	}
+
+	strategy := r.RestartStrategy
+	if strategy == "" {
+		strategy = RestartStrategyDeletePod
+	}
+	if strategy != RestartStrategyNone {
+		retryAfter, err := r.checkRestartBudget(pod, time.Now())
+		if err != nil {
+			leaseLog.Error(err, "Failed to check the restart budget", "errorClass", ErrorClass(err))
+			return 0, err
+		}
+		if retryAfter > 0 {
+			podRestartsTotal.WithLabelValues(string(strategy), "suppressed").Inc()
+			leaseLog.Info("Restart budget exhausted, the pod restart is suppressed",
+				"pod", pod.Name, "podNamespace", pod.Namespace, "retryAfter", retryAfter)
+			return retryAfter, nil
+		}
+	}
+	r.recordEvent(pod, corev1.EventTypeNormal, ReasonHubSecretRotated,
+		fmt.Sprintf("The hub secret changed and only the new one works, restarting with strategy %s", strategy))
+
+	var err error
+	switch strategy {
+	case RestartStrategyDeletePod:
+		err = r.deletePod(pod)
+	case RestartStrategyRolloutRestart:
+		err = r.rolloutRestart(pod)
+	case RestartStrategyAnnotatePod:
+		err = r.annotatePodRestart(pod)
+	case RestartStrategyNone:
+		leaseLog.Info("The pod must be restarted to use the new secret",
+			"pod", pod.Name, "podNamespace", pod.Namespace, "strategy", string(strategy))
+	default:
+		err = fmt.Errorf("unknown restart strategy %q", strategy)
+	}
+	if err != nil {
+		leaseLog.Error(err, "Failed to restart the pod", "pod", pod.Name, "podNamespace", pod.Namespace,
+			"strategy", string(strategy), "errorClass", ErrorClass(err))
+		return 0, err
+	}
+	if strategy == RestartStrategyNone {
+		podRestartsTotal.WithLabelValues(string(strategy), "skipped").Inc()
+		return 0, nil
+	}
+	podRestartsTotal.WithLabelValues(string(strategy), "performed").Inc()
+	// only the restarts performed take the budget
+	if err := r.recordRestart(time.Now()); err != nil {
+		leaseLog.Error(err, "Failed to record the restart in the restart budget", "errorClass", ErrorClass(err))
+		return 0, err
+	}
+	return 0, nil
+}
+
+// loadRestartBudget reads the restart budget ConfigMap and returns the restart times within the window,
+// and whether the ConfigMap exists
+func (r *LeaseReconciler) loadRestartBudget(now time.Time) (*corev1.ConfigMap, []time.Time, bool, error) {
+	cm := &corev1.ConfigMap{}
+	err := r.reader().Get(context.TODO(), types.NamespacedName{Name: r.RestartBudget.ConfigMapName, Namespace: r.PodNamespace}, cm)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, nil, false, err
+	}
+	exists := err == nil
+
+	restarts := []time.Time{}
+	if data, ok := cm.Data[restartBudgetRestartsKey]; ok {
+		if err := json.Unmarshal([]byte(data), &restarts); err != nil {
+			leaseLog.Error(err, "Ignoring the invalid restart times", "configMap", cm.Namespace+"/"+cm.Name)
+		}
+	}
+	inWindow := []time.Time{}
+	for _, restart := range restarts {
+		if now.Sub(restart) < r.RestartBudget.Window {
+			inWindow = append(inWindow, restart)
+		}
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	return cm, inWindow, exists, nil
+}
+
+// checkRestartBudget returns the time until the oldest restart leaves the window if the restart budget is exhausted,
+// 0 if a restart is allowed. The exhausted budget is reported once.
+func (r *LeaseReconciler) checkRestartBudget(pod *corev1.Pod, now time.Time) (time.Duration, error) {
+	if r.RestartBudget.MaxRestarts <= 0 {
+		return 0, nil
+	}
+	cm, inWindow, exists, err := r.loadRestartBudget(now)
+	if err != nil {
+		return 0, err
+	}
+	if len(inWindow) < r.RestartBudget.MaxRestarts {
+		return 0, nil
+	}
+	retryAfter := inWindow[0].Add(r.RestartBudget.Window).Sub(now)
+	if cm.Data[restartBudgetExhaustedKey] == "true" {
+		return retryAfter, nil
+	}
+	r.recordEvent(pod, corev1.EventTypeWarning, ReasonRestartBudgetExhausted,
+		fmt.Sprintf("%d restarts within %s, the restarts are suppressed for %s",
+			len(inWindow), r.RestartBudget.Window, retryAfter))
+	cm.Data[restartBudgetExhaustedKey] = "true"
+	return retryAfter, r.saveRestartBudget(cm, exists)
+}
+
+// recordRestart records a restart performed in the restart budget
+func (r *LeaseReconciler) recordRestart(now time.Time) error {
+	if r.RestartBudget.MaxRestarts <= 0 {
+		return nil
+	}
+	cm, inWindow, exists, err := r.loadRestartBudget(now)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(append(inWindow, now))
+	if err != nil {
+		return err
+	}
+	cm.Data[restartBudgetRestartsKey] = string(data)
+	delete(cm.Data, restartBudgetExhaustedKey)
+	return r.saveRestartBudget(cm, exists)
+}
+
+// saveRestartBudget creates or updates the restart budget ConfigMap
+func (r *LeaseReconciler) saveRestartBudget(cm *corev1.ConfigMap, exists bool) error {
+	if exists {
+		return r.Client.Update(context.TODO(), cm)
+	}
+	cm.Name = r.RestartBudget.ConfigMapName
+	cm.Namespace = r.PodNamespace
+	return r.Client.Create(context.TODO(), cm)
+}
+
+// deletePod delete the current pod
+func (r *LeaseReconciler) deletePod(pod *corev1.Pod) error {
+	return r.Client.Delete(context.TODO(), pod)
+}
+
+// rolloutRestart annotates the pod template of the Deployment owning the pod to roll out new pods
+func (r *LeaseReconciler) rolloutRestart(pod *corev1.Pod) error {
+	rsRef := metav1.GetControllerOf(pod)
+	if rsRef == nil || rsRef.Kind != "ReplicaSet" {
+		return fmt.Errorf("pod %s/%s is not owned by a ReplicaSet", pod.Namespace, pod.Name)
+	}
+	rs := &appsv1.ReplicaSet{}
+	if err := r.reader().Get(context.TODO(), types.NamespacedName{Name: rsRef.Name, Namespace: pod.Namespace}, rs); err != nil {
+		return err
+	}
+	deploymentRef := metav1.GetControllerOf(rs)
+	if deploymentRef == nil || deploymentRef.Kind != "Deployment" {
+		return fmt.Errorf("replicaset %s/%s is not owned by a Deployment", rs.Namespace, rs.Name)
+	}
+	deployment := &appsv1.Deployment{}
+	if err := r.reader().Get(context.TODO(), types.NamespacedName{Name: deploymentRef.Name, Namespace: pod.Namespace}, deployment); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(deployment.DeepCopy())
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.Annotations[rolloutRestartAnnotation] = time.Now().Format(time.RFC3339)
+	leaseLog.Info("Rolling out the deployment", "deployment", deployment.Name, "podNamespace", deployment.Namespace)
+	return r.Client.Patch(context.TODO(), deployment, patch)
+}
+
+// annotatePodRestart signals the restart container through a restart annotation on the pod
+func (r *LeaseReconciler) annotatePodRestart(pod *corev1.Pod) error {
+	if r.RestartContainer == "" {
+		return fmt.Errorf("no restart container set for the restart strategy %s", RestartStrategyAnnotatePod)
+	}
+	patch := client.MergeFrom(pod.DeepCopy())
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[ContainerRestartAnnotation(r.RestartContainer)] = time.Now().Format(time.RFC3339)
+	leaseLog.Info("Signaling the container to restart", "container", r.RestartContainer, "pod", pod.Name, "podNamespace", pod.Namespace)
+	return r.Client.Patch(context.TODO(), pod, patch)
+}
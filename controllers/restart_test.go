@@ -0,0 +1,409 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestOwnedPod() []runtime.Object {
+	controller := true
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "addon",
+			Namespace: podNamespace,
+		},
+	}
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "addon-1234",
+			Namespace: podNamespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "Deployment", Name: "addon", Controller: &controller},
+			},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podName,
+			Namespace: podNamespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "addon-1234", Controller: &controller},
+			},
+		},
+	}
+	return []runtime.Object{deployment, rs, pod}
+}
+
+func TestLeaseReconciler_restartPod(t *testing.T) {
+	orphanPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podName,
+			Namespace: podNamespace,
+		},
+	}
+	tests := []struct {
+		name             string
+		objects          []runtime.Object
+		strategy         RestartStrategy
+		restartContainer string
+		wantErr          bool
+		check            func(t *testing.T, c client.Client)
+	}{
+		{
+			name:     "default strategy deletes the pod",
+			objects:  newTestOwnedPod(),
+			strategy: "",
+			check: func(t *testing.T, c client.Client) {
+				err := c.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: podNamespace}, &corev1.Pod{})
+				if !errors.IsNotFound(err) {
+					t.Errorf("pod not deleted: %v", err)
+				}
+			},
+		},
+		{
+			name:     "rollout restart",
+			objects:  newTestOwnedPod(),
+			strategy: RestartStrategyRolloutRestart,
+			check: func(t *testing.T, c client.Client) {
+				deployment := &appsv1.Deployment{}
+				if err := c.Get(context.TODO(), types.NamespacedName{Name: "addon", Namespace: podNamespace}, deployment); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, ok := deployment.Spec.Template.Annotations[rolloutRestartAnnotation]; !ok {
+					t.Error("deployment pod template not annotated")
+				}
+			},
+		},
+		{
+			name:     "rollout restart without deployment",
+			objects:  []runtime.Object{orphanPod},
+			strategy: RestartStrategyRolloutRestart,
+			wantErr:  true,
+		},
+		{
+			name:             "annotate pod",
+			objects:          newTestOwnedPod(),
+			strategy:         RestartStrategyAnnotatePod,
+			restartContainer: "addon-agent",
+			check: func(t *testing.T, c client.Client) {
+				pod := &corev1.Pod{}
+				if err := c.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: podNamespace}, pod); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, ok := pod.Annotations[ContainerRestartAnnotation("addon-agent")]; !ok {
+					t.Error("pod not annotated")
+				}
+			},
+		},
+		{
+			name:     "annotate pod without container",
+			objects:  newTestOwnedPod(),
+			strategy: RestartStrategyAnnotatePod,
+			wantErr:  true,
+		},
+		{
+			name:     "none",
+			objects:  newTestOwnedPod(),
+			strategy: RestartStrategyNone,
+			check: func(t *testing.T, c client.Client) {
+				if err := c.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: podNamespace}, &corev1.Pod{}); err != nil {
+					t.Errorf("pod must not be deleted: %v", err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClientWithScheme(scheme.Scheme, tt.objects...)
+			recorder := record.NewFakeRecorder(10)
+			r := &LeaseReconciler{
+				Client:           c,
+				PodName:          podName,
+				PodNamespace:     podNamespace,
+				Recorder:         recorder,
+				RestartStrategy:  tt.strategy,
+				RestartContainer: tt.restartContainer,
+			}
+			_, err := r.restartPod()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LeaseReconciler.restartPod() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			select {
+			case event := <-recorder.Events:
+				if !strings.Contains(event, ReasonHubSecretRotated) {
+					t.Errorf("LeaseReconciler.restartPod() event = %s, want reason %s", event, ReasonHubSecretRotated)
+				}
+			default:
+				t.Error("LeaseReconciler.restartPod() no event recorded")
+			}
+			if tt.check != nil {
+				tt.check(t, c)
+			}
+		})
+	}
+}
+
+func TestLeaseReconciler_restartPod_budget(t *testing.T) {
+	now := time.Now()
+	history := func(restarts ...time.Time) string {
+		data, _ := json.Marshal(restarts)
+		return string(data)
+	}
+	tests := []struct {
+		name           string
+		data           map[string]string
+		strategy       RestartStrategy
+		wantRetryAfter time.Duration
+		wantEvent      string
+		wantRestarts   int
+		wantErr        bool
+	}{
+		{
+			name:         "no history",
+			data:         nil,
+			wantEvent:    ReasonHubSecretRotated,
+			wantRestarts: 1,
+		},
+		{
+			name: "old restarts out of the window",
+			data: map[string]string{
+				restartBudgetRestartsKey: history(now.Add(-3*time.Hour), now.Add(-2*time.Hour)),
+			},
+			wantEvent:    ReasonHubSecretRotated,
+			wantRestarts: 1,
+		},
+		{
+			name: "failed restart not recorded",
+			data: map[string]string{
+				restartBudgetRestartsKey: history(now.Add(-10 * time.Minute)),
+			},
+			// the AnnotatePod strategy fails without restart container
+			strategy:     RestartStrategyAnnotatePod,
+			wantEvent:    ReasonHubSecretRotated,
+			wantRestarts: 1,
+			wantErr:      true,
+		},
+		{
+			name: "budget exhausted",
+			data: map[string]string{
+				restartBudgetRestartsKey: history(now.Add(-30*time.Minute), now.Add(-10*time.Minute)),
+			},
+			wantRetryAfter: 30 * time.Minute,
+			wantEvent:      ReasonRestartBudgetExhausted,
+			wantRestarts:   2,
+		},
+		{
+			name: "budget exhausted already reported",
+			data: map[string]string{
+				restartBudgetRestartsKey:  history(now.Add(-30*time.Minute), now.Add(-10*time.Minute)),
+				restartBudgetExhaustedKey: "true",
+			},
+			wantRetryAfter: 30 * time.Minute,
+			wantEvent:      "",
+			wantRestarts:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := newTestOwnedPod()
+			if tt.data != nil {
+				objects = append(objects, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "restart-budget", Namespace: podNamespace},
+					Data:       tt.data,
+				})
+			}
+			c := fake.NewFakeClientWithScheme(scheme.Scheme, objects...)
+			recorder := record.NewFakeRecorder(10)
+			r := &LeaseReconciler{
+				Client:          c,
+				PodName:         podName,
+				PodNamespace:    podNamespace,
+				Recorder:        recorder,
+				RestartStrategy: tt.strategy,
+				RestartBudget: RestartBudget{
+					MaxRestarts:   2,
+					Window:        time.Hour,
+					ConfigMapName: "restart-budget",
+				},
+			}
+			retryAfter, err := r.restartPod()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LeaseReconciler.restartPod() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if (retryAfter > 0) != (tt.wantRetryAfter > 0) || retryAfter > tt.wantRetryAfter {
+				t.Errorf("LeaseReconciler.restartPod() retryAfter = %s, want %s", retryAfter, tt.wantRetryAfter)
+			}
+			select {
+			case event := <-recorder.Events:
+				if tt.wantEvent == "" || !strings.Contains(event, tt.wantEvent) {
+					t.Errorf("LeaseReconciler.restartPod() event = %s, want reason %q", event, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("LeaseReconciler.restartPod() no event recorded, want reason %s", tt.wantEvent)
+				}
+			}
+			cm := &corev1.ConfigMap{}
+			if err := c.Get(context.TODO(), types.NamespacedName{Name: "restart-budget", Namespace: podNamespace}, cm); err != nil {
+				t.Error(err)
+				return
+			}
+			restarts := []time.Time{}
+			if err := json.Unmarshal([]byte(cm.Data[restartBudgetRestartsKey]), &restarts); err != nil {
+				t.Error(err)
+			}
+			if len(restarts) != tt.wantRestarts {
+				t.Errorf("LeaseReconciler.restartPod() %d restarts recorded, want %d", len(restarts), tt.wantRestarts)
+			}
+			if tt.wantErr {
+				return
+			}
+			podDeleted := errors.IsNotFound(c.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: podNamespace}, &corev1.Pod{}))
+			if podDeleted != (tt.wantRetryAfter == 0) {
+				t.Errorf("LeaseReconciler.restartPod() pod deleted = %v, want %v", podDeleted, tt.wantRetryAfter == 0)
+			}
+		})
+	}
+}
+
+func TestParseRestartStrategy(t *testing.T) {
+	for strategy, want := range map[string]RestartStrategy{
+		"":               RestartStrategyDeletePod,
+		"RolloutRestart": RestartStrategyRolloutRestart,
+		"None":           RestartStrategyNone,
+	} {
+		if got, err := ParseRestartStrategy(strategy); err != nil || got != want {
+			t.Errorf("ParseRestartStrategy(%q) = %v, %v, want %v", strategy, got, err, want)
+		}
+	}
+	if _, err := ParseRestartStrategy("Reboot"); err == nil {
+		t.Error("ParseRestartStrategy() must fail for an unknown strategy")
+	}
+}
+
+func TestLeaseReconciler_Reconcile_rotationWithoutRestart(t *testing.T) {
+	oldSecret := &corev1.Secret{Data: map[string][]byte{"kubeconfig": []byte("old")}}
+	newSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "hub-secret", Namespace: podNamespace},
+		Data:       map[string][]byte{"kubeconfig": []byte("new")},
+	}
+	exhausted, _ := json.Marshal([]time.Time{time.Now().Add(-10 * time.Minute)})
+	tests := []struct {
+		name          string
+		strategy      RestartStrategy
+		budget        RestartBudget
+		want          ctrl.Result
+		wantNewSecret bool
+	}{
+		{
+			name:          "annotate pod",
+			strategy:      RestartStrategyAnnotatePod,
+			wantNewSecret: true,
+		},
+		{
+			name:          "none",
+			strategy:      RestartStrategyNone,
+			wantNewSecret: true,
+		},
+		{
+			name:          "restart suppressed by the budget",
+			strategy:      RestartStrategyDeletePod,
+			budget:        RestartBudget{MaxRestarts: 1, Window: time.Hour, ConfigMapName: "restart-budget"},
+			want:          ctrl.Result{Requeue: true, RequeueAfter: 50 * time.Minute},
+			wantNewSecret: true,
+		},
+		{
+			name:          "pod deleted",
+			strategy:      RestartStrategyDeletePod,
+			wantNewSecret: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := append(newTestOwnedPod(), newSecret.DeepCopy(), &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "restart-budget", Namespace: podNamespace},
+				Data:       map[string]string{restartBudgetRestartsKey: string(exhausted)},
+			})
+			hubClient := fakekubeclient.NewSimpleClientset()
+			oldUpdater := &leaseUpdater{}
+			r := &LeaseReconciler{
+				Client:               fake.NewFakeClientWithScheme(scheme.Scheme, objects...),
+				Log:                  ctrl.Log.WithName("controllers").WithName("Lease"),
+				LeaseName:            leaseName,
+				LeaseNamespace:       leaseNamespace,
+				HubConfigSecretName:  "hub-secret",
+				LeaseDurationSeconds: 1,
+				PodName:              podName,
+				PodNamespace:         podNamespace,
+				SkipPodReadyCheck:    true,
+				RestartStrategy:      tt.strategy,
+				RestartContainer:     "addon-agent",
+				RestartBudget:        tt.budget,
+				BuildKubeClientWithSecretFunc: func(secret *corev1.Secret) (kubernetes.Interface, error) {
+					return hubClient, nil
+				},
+				// only the new secret works
+				CheckLeaseUpdaterClient: func(ctx context.Context, u *leaseUpdater) bool { return u != oldUpdater },
+				hubLeases:               map[string]*hubLease{"hub-secret": {leaseUpdater: oldUpdater, cachedSecret: oldSecret}},
+			}
+			got, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Namespace: podNamespace, Name: "hub-secret"}})
+			if err != nil {
+				t.Fatalf("LeaseReconciler.Reconcile() error = %v", err)
+			}
+			if got.Requeue != tt.want.Requeue || got.RequeueAfter > tt.want.RequeueAfter {
+				t.Errorf("LeaseReconciler.Reconcile() = %v, want %v", got, tt.want)
+			}
+			h := r.hubLeases["hub-secret"]
+			if swapped := h.leaseUpdater != oldUpdater; swapped != tt.wantNewSecret {
+				t.Fatalf("LeaseReconciler.Reconcile() lease updater replaced = %v, want %v", swapped, tt.wantNewSecret)
+			}
+			if !tt.wantNewSecret {
+				return
+			}
+			defer h.leaseUpdater.stop(context.TODO())
+			if string(h.cachedSecret.Data["kubeconfig"]) != "new" {
+				t.Error("LeaseReconciler.Reconcile() the new secret is not cached")
+			}
+			if h.restartPending != tt.want.Requeue {
+				t.Errorf("LeaseReconciler.Reconcile() restart pending = %v, want %v", h.restartPending, tt.want.Requeue)
+			}
+			// the lease keeps being renewed with the new secret
+			renewals := map[time.Time]bool{}
+			err = wait.Poll(100*time.Millisecond, 5*time.Second, func() (bool, error) {
+				lease, err := hubClient.CoordinationV1().Leases(leaseNamespace).Get(context.TODO(), leaseName, metav1.GetOptions{})
+				if err != nil || lease.Spec.RenewTime == nil {
+					return false, err
+				}
+				renewals[lease.Spec.RenewTime.Time] = true
+				return len(renewals) >= 2, nil
+			})
+			if err != nil {
+				t.Errorf("LeaseReconciler.Reconcile() the lease is not renewed with the new secret: %v", err)
+			}
+		})
+	}
+}
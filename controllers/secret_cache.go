@@ -0,0 +1,119 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// SecretCache caches only the hub secrets, each one is watched by its own informer with a field selector on its name,
+// so the other secrets are not kept in memory and the RBAC can be scoped to the hub secret resourceNames.
+// It implements the manager Runnable interface and the client Reader interface for the secrets.
+type SecretCache struct {
+	namespace string
+	informers map[string]cache.SharedIndexInformer
+}
+
+var _ client.Reader = &SecretCache{}
+
+// NewSecretCache returns a cache of the secrets names in namespace, all namespaces if namespace is empty
+func NewSecretCache(kubeClient kubernetes.Interface, namespace string, names []string, resync time.Duration) *SecretCache {
+	c := &SecretCache{
+		namespace: namespace,
+		informers: map[string]cache.SharedIndexInformer{},
+	}
+	for _, name := range names {
+		selector := fields.OneTermEqualSelector("metadata.name", name).String()
+		c.informers[name] = cache.NewSharedIndexInformer(
+			&cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					options.FieldSelector = selector
+					return kubeClient.CoreV1().Secrets(namespace).List(context.TODO(), options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					options.FieldSelector = selector
+					return kubeClient.CoreV1().Secrets(namespace).Watch(context.TODO(), options)
+				},
+			},
+			&corev1.Secret{},
+			resync,
+			cache.Indexers{},
+		)
+	}
+	return c
+}
+
+// Start runs the informers until stop is closed
+func (c *SecretCache) Start(stop <-chan struct{}) error {
+	for name, informer := range c.informers {
+		leaseLog.Info("Start watching the hub secret", "hub", name, "secretNamespace", c.namespace)
+		go informer.Run(stop)
+	}
+	<-stop
+	return nil
+}
+
+// HasSynced returns true once all the secrets are synced
+func (c *SecretCache) HasSynced() bool {
+	for _, informer := range c.informers {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Sources returns the event sources of the secrets, to be watched by the controller
+func (c *SecretCache) Sources() []source.Source {
+	sources := []source.Source{}
+	for _, informer := range c.informers {
+		sources = append(sources, &source.Informer{Informer: informer})
+	}
+	return sources
+}
+
+// Get reads a secret from the cache
+func (c *SecretCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return fmt.Errorf("the secret cache can not get %T", obj)
+	}
+	informer, ok := c.informers[key.Name]
+	if !ok {
+		return fmt.Errorf("secret %s is not cached", key.Name)
+	}
+	if !informer.HasSynced() {
+		return fmt.Errorf("secret %s not synced yet", key.Name)
+	}
+	storeKey := key.Name
+	if key.Namespace != "" {
+		storeKey = key.Namespace + "/" + key.Name
+	}
+	item, exists, err := informer.GetStore().GetByKey(storeKey)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.NewNotFound(corev1.Resource("secrets"), key.Name)
+	}
+	item.(*corev1.Secret).DeepCopyInto(secret)
+	return nil
+}
+
+// List is not supported, the secrets are read one by one
+func (c *SecretCache) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	return fmt.Errorf("the secret cache does not support list")
+}
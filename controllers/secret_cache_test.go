@@ -0,0 +1,74 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSecretCache(t *testing.T) {
+	kubeClient := fakekubeclient.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "hub-secret", Namespace: "addon-ns"},
+			Data:       map[string][]byte{"kubeconfig": []byte("fake")},
+		},
+	)
+	c := NewSecretCache(kubeClient, "addon-ns", []string{"hub-secret", "standby-hub-secret"}, 0)
+	if len(c.Sources()) != 2 {
+		t.Errorf("SecretCache.Sources() = %d sources, want 2", len(c.Sources()))
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go c.Start(stop)
+	if !cache.WaitForCacheSync(stop, c.HasSynced) {
+		t.Fatal("SecretCache not synced")
+	}
+
+	tests := []struct {
+		name         string
+		key          types.NamespacedName
+		wantErr      bool
+		wantNotFound bool
+	}{
+		{
+			name: "cached secret",
+			key:  types.NamespacedName{Name: "hub-secret", Namespace: "addon-ns"},
+		},
+		{
+			name:         "cached secret not found",
+			key:          types.NamespacedName{Name: "standby-hub-secret", Namespace: "addon-ns"},
+			wantErr:      true,
+			wantNotFound: true,
+		},
+		{
+			name:    "secret not cached",
+			key:     types.NamespacedName{Name: "other-secret", Namespace: "addon-ns"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &corev1.Secret{}
+			err := c.Get(context.TODO(), tt.key, secret)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SecretCache.Get() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if errors.IsNotFound(err) != tt.wantNotFound {
+				t.Errorf("SecretCache.Get() error = %v, wantNotFound %v", err, tt.wantNotFound)
+			}
+			if err == nil && string(secret.Data["kubeconfig"]) != "fake" {
+				t.Errorf("SecretCache.Get() = %v", secret)
+			}
+		})
+	}
+}
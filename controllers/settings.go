@@ -0,0 +1,90 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// LeaseSettings are the lease parameters which can be changed at runtime
+type LeaseSettings struct {
+	LeaseName            string
+	LeaseNamespace       string
+	LeaseDurationSeconds int32
+	// RenewInterval is the interval between the lease renewals, the lease duration if 0
+	RenewInterval time.Duration
+	// SkipPodReadyCheck renews the lease even if the pod is not ready
+	SkipPodReadyCheck bool
+}
+
+// Settings returns the current lease settings
+func (r *LeaseReconciler) Settings() LeaseSettings {
+	r.settingsLock.Lock()
+	defer r.settingsLock.Unlock()
+	return r.settings()
+}
+
+func (r *LeaseReconciler) settings() LeaseSettings {
+	return LeaseSettings{
+		LeaseName:            r.LeaseName,
+		LeaseNamespace:       r.LeaseNamespace,
+		LeaseDurationSeconds: r.LeaseDurationSeconds,
+		RenewInterval:        r.RenewInterval,
+		SkipPodReadyCheck:    r.SkipPodReadyCheck,
+	}
+}
+
+// ApplySettings changes the lease settings at runtime. The running lease updaters are stopped
+// and the hub secrets reconciled again, so the updaters are restarted with the new settings
+// and the lease duration is updated on the hubs. The updaters are restarted with the hub secret
+// they used, so a change of the hub secret is still detected.
+// It returns false if the settings did not change.
+func (r *LeaseReconciler) ApplySettings(s LeaseSettings) bool {
+	r.settingsLock.Lock()
+	defer r.settingsLock.Unlock()
+	current := r.settings()
+	if s == current {
+		return false
+	}
+	changes := []interface{}{}
+	if s.LeaseName != current.LeaseName {
+		changes = append(changes, "leaseName", s.LeaseName, "previousLeaseName", current.LeaseName)
+	}
+	if s.LeaseNamespace != current.LeaseNamespace {
+		changes = append(changes, "leaseNamespace", s.LeaseNamespace, "previousLeaseNamespace", current.LeaseNamespace)
+	}
+	if s.LeaseDurationSeconds != current.LeaseDurationSeconds {
+		changes = append(changes, "leaseDurationSeconds", s.LeaseDurationSeconds, "previousLeaseDurationSeconds", current.LeaseDurationSeconds)
+	}
+	if s.RenewInterval != current.RenewInterval {
+		changes = append(changes, "renewInterval", s.RenewInterval.String(), "previousRenewInterval", current.RenewInterval.String())
+	}
+	if s.SkipPodReadyCheck != current.SkipPodReadyCheck {
+		changes = append(changes, "skipPodReadyCheck", s.SkipPodReadyCheck, "previousSkipPodReadyCheck", current.SkipPodReadyCheck)
+	}
+	leaseLog.Info("Apply the lease settings", changes...)
+	if s.LeaseName != current.LeaseName || s.LeaseNamespace != current.LeaseNamespace {
+		leaseLog.Info("The lease is no longer renewed, another lease is renewed instead",
+			"lease", current.LeaseName, "namespace", current.LeaseNamespace, "newLease", s.LeaseName, "newNamespace", s.LeaseNamespace)
+	}
+	r.LeaseName = s.LeaseName
+	r.LeaseNamespace = s.LeaseNamespace
+	r.LeaseDurationSeconds = s.LeaseDurationSeconds
+	r.RenewInterval = s.RenewInterval
+	r.SkipPodReadyCheck = s.SkipPodReadyCheck
+
+	for _, h := range r.hubLeases {
+		if h.leaseUpdater == nil {
+			continue
+		}
+		h.leaseUpdater.stop(context.TODO())
+		h.leaseUpdater = nil
+		if r.settingsChanged != nil && h.cachedSecret != nil {
+			r.settingsChanged <- event.GenericEvent{Meta: h.cachedSecret, Object: h.cachedSecret}
+		}
+	}
+	return true
+}
@@ -0,0 +1,209 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestLeaseReconciler_ApplySettings(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "hub-secret",
+			Namespace: "addon-ns",
+		},
+	}
+	hubClient := fakekubeclient.NewSimpleClientset()
+	r := &LeaseReconciler{
+		Client:               fake.NewFakeClientWithScheme(scheme.Scheme, secret),
+		Log:                  ctrl.Log.WithName("controllers").WithName("Lease"),
+		Scheme:               scheme.Scheme,
+		LeaseName:            leaseName,
+		LeaseNamespace:       leaseNamespace,
+		HubConfigSecretName:  "hub-secret",
+		LeaseDurationSeconds: 60,
+		BuildKubeClientWithSecretFunc: func(secret *corev1.Secret) (kubernetes.Interface, error) {
+			return hubClient, nil
+		},
+		settingsChanged: make(chan event.GenericEvent, 1),
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "addon-ns", Name: "hub-secret"}}
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("LeaseReconciler.Reconcile() error = %v", err)
+	}
+	u := r.hubLeases["hub-secret"].leaseUpdater
+	if u == nil {
+		t.Fatal("LeaseReconciler.Reconcile() no lease updater")
+	}
+
+	if r.ApplySettings(r.Settings()) {
+		t.Error("LeaseReconciler.ApplySettings() = true for the same settings")
+	}
+	if r.hubLeases["hub-secret"].leaseUpdater != u {
+		t.Error("LeaseReconciler.ApplySettings() restarted the lease updater for the same settings")
+	}
+
+	settings := LeaseSettings{
+		LeaseName:            "new-lease",
+		LeaseNamespace:       leaseNamespace,
+		LeaseDurationSeconds: 120,
+		RenewInterval:        30 * time.Second,
+		SkipPodReadyCheck:    true,
+	}
+	if !r.ApplySettings(settings) {
+		t.Error("LeaseReconciler.ApplySettings() = false for new settings")
+	}
+	if r.hubLeases["hub-secret"].leaseUpdater != nil {
+		t.Error("LeaseReconciler.ApplySettings() the lease updater is not stopped")
+	}
+	if u.cancel != nil {
+		t.Error("LeaseReconciler.ApplySettings() the previous lease updater is still running")
+	}
+	select {
+	case e := <-r.settingsChanged:
+		if e.Meta.GetName() != "hub-secret" {
+			t.Errorf("LeaseReconciler.ApplySettings() reconciles %s, want hub-secret", e.Meta.GetName())
+		}
+	default:
+		t.Error("LeaseReconciler.ApplySettings() the hub secret is not reconciled again")
+	}
+
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("LeaseReconciler.Reconcile() error = %v", err)
+	}
+	u = r.hubLeases["hub-secret"].leaseUpdater
+	if u == nil {
+		t.Fatal("LeaseReconciler.Reconcile() the lease updater is not restarted")
+	}
+	defer u.stop(context.TODO())
+	if u.name != "new-lease" || u.renewInterval != 30*time.Second || u.checkPodIsRunning != nil {
+		t.Errorf("LeaseReconciler.Reconcile() lease updater %s, renew interval %s, pod check %v",
+			u.name, u.renewInterval, u.checkPodIsRunning != nil)
+	}
+	lease, err := hubClient.CoordinationV1().Leases(leaseNamespace).Get(context.TODO(), "new-lease", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Lease not found on hub: %v", err)
+	}
+	if lease.Spec.LeaseDurationSeconds == nil || *lease.Spec.LeaseDurationSeconds != 120 {
+		t.Errorf("Lease duration = %v, want 120", lease.Spec.LeaseDurationSeconds)
+	}
+}
+
+func TestLeaseReconciler_ApplySettings_secretChange(t *testing.T) {
+	tests := []struct {
+		name           string
+		oldWorks       bool
+		want           ctrl.Result
+		wantKubeConfig string
+	}{
+		{
+			name:           "both secrets work",
+			oldWorks:       true,
+			want:           ctrl.Result{Requeue: true, RequeueAfter: 60 * time.Second},
+			wantKubeConfig: "old",
+		},
+		{
+			name:           "only the new secret works",
+			want:           ctrl.Result{},
+			wantKubeConfig: "new",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "hub-secret", Namespace: podNamespace},
+				Data:       map[string][]byte{"kubeconfig": []byte("old")},
+			}
+			c := fake.NewFakeClientWithScheme(scheme.Scheme, append(newTestOwnedPod(), secret)...)
+			oldClient, newClient := fakekubeclient.NewSimpleClientset(), fakekubeclient.NewSimpleClientset()
+			working := map[kubernetes.Interface]bool{oldClient: true, newClient: true}
+			r := &LeaseReconciler{
+				Client:               c,
+				Log:                  ctrl.Log.WithName("controllers").WithName("Lease"),
+				LeaseName:            leaseName,
+				LeaseNamespace:       leaseNamespace,
+				HubConfigSecretName:  "hub-secret",
+				LeaseDurationSeconds: 60,
+				PodName:              podName,
+				PodNamespace:         podNamespace,
+				SkipPodReadyCheck:    true,
+				RestartStrategy:      RestartStrategyNone,
+				BuildKubeClientWithSecretFunc: func(secret *corev1.Secret) (kubernetes.Interface, error) {
+					if string(secret.Data["kubeconfig"]) == "new" {
+						return newClient, nil
+					}
+					return oldClient, nil
+				},
+				CheckLeaseUpdaterClient: func(ctx context.Context, u *leaseUpdater) bool {
+					return working[u.getHubClient()]
+				},
+			}
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: podNamespace, Name: "hub-secret"}}
+			if _, err := r.Reconcile(req); err != nil {
+				t.Fatalf("LeaseReconciler.Reconcile() error = %v", err)
+			}
+
+			// the hub secret changes, then the settings are reloaded before the change is handled
+			secret.Data["kubeconfig"] = []byte("new")
+			if err := c.Update(context.TODO(), secret); err != nil {
+				t.Fatal(err)
+			}
+			working[oldClient] = tt.oldWorks
+			settings := r.Settings()
+			settings.RenewInterval = 30 * time.Second
+			if !r.ApplySettings(settings) {
+				t.Fatal("LeaseReconciler.ApplySettings() = false for new settings")
+			}
+
+			got, err := r.Reconcile(req)
+			if err != nil || got != tt.want {
+				t.Errorf("LeaseReconciler.Reconcile() = %v, %v, want %v", got, err, tt.want)
+			}
+			h := r.hubLeases["hub-secret"]
+			if h.leaseUpdater == nil {
+				t.Fatal("LeaseReconciler.Reconcile() the lease updater is not restarted")
+			}
+			defer h.leaseUpdater.stop(context.TODO())
+			if kubeConfig := string(h.cachedSecret.Data["kubeconfig"]); kubeConfig != tt.wantKubeConfig {
+				t.Errorf("LeaseReconciler.Reconcile() cached secret %s, want %s", kubeConfig, tt.wantKubeConfig)
+			}
+			if h.leaseUpdater.renewInterval != 30*time.Second {
+				t.Errorf("LeaseReconciler.Reconcile() renew interval %s, want 30s", h.leaseUpdater.renewInterval)
+			}
+		})
+	}
+}
+
+func TestLeaseUpdater_ensureLease_duration(t *testing.T) {
+	hubClient := fakekubeclient.NewSimpleClientset()
+	u := &leaseUpdater{
+		hubClient: hubClient,
+		name:      leaseName,
+		namespace: leaseNamespace,
+	}
+	for _, duration := range []int32{60, 60, 30} {
+		d := duration
+		if err := u.ensureLease(context.TODO(), &d); err != nil {
+			t.Fatalf("leaseUpdater.ensureLease() error = %v", err)
+		}
+		lease, err := hubClient.CoordinationV1().Leases(leaseNamespace).Get(context.TODO(), leaseName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Lease not found on hub: %v", err)
+		}
+		if *lease.Spec.LeaseDurationSeconds != duration {
+			t.Errorf("Lease duration = %d, want %d", *lease.Spec.LeaseDurationSeconds, duration)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const defaultStartupPollInterval = 2 * time.Second
+
+// StartupGate holds the startup until the lease can be renewed: the pod is ready,
+// the hub secrets are present and the hubs are reachable.
+// Each condition is waited for up to its own timeout, 0 skips the wait. On timeout the startup goes on
+// and the controller keeps checking the condition before renewing the lease.
+type StartupGate struct {
+	// Reader reads the pod and the secrets, the manager cache is not started yet
+	Reader                   client.Reader
+	PodName                  string
+	PodNamespace             string
+	HubConfigSecretNames     []string
+	HubConfigSecretNamespace string
+	LeaseName                string
+	// LeaseNamespace is the namespace of the lease on the hubs, empty skips the hubs reachable condition,
+	// for example when the namespace is derived from each hub secret
+	LeaseNamespace                  string
+	BuildHubEndpointsWithSecretFunc IBuildHubEndpointsWithSecret
+	// MinDelay is the minimum startup delay, whatever the conditions
+	MinDelay            time.Duration
+	PodReadyTimeout     time.Duration
+	HubSecretTimeout    time.Duration
+	HubReachableTimeout time.Duration
+	// PollInterval is the interval between two checks of a condition, default 2 seconds
+	PollInterval time.Duration
+}
+
+// Wait waits for the startup conditions, it returns early if stop is closed
+func (g *StartupGate) Wait(stop <-chan struct{}) {
+	start := time.Now()
+
+	g.waitFor(stop, "pod ready", g.PodReadyTimeout, g.podReady)
+
+	secrets := map[string]*corev1.Secret{}
+	g.waitFor(stop, "hub secrets present", g.HubSecretTimeout, func() (bool, error) {
+		return g.hubSecretsPresent(secrets)
+	})
+
+	if len(secrets) != 0 && g.LeaseNamespace != "" {
+		g.waitFor(stop, "hubs reachable", g.HubReachableTimeout, func() (bool, error) {
+			return g.hubsReachable(secrets)
+		})
+	}
+
+	if delay := g.MinDelay - time.Since(start); delay > 0 {
+		leaseLog.Info("Waiting to startup...", "delay", delay)
+		select {
+		case <-time.After(delay):
+		case <-stop:
+		}
+	}
+}
+
+// waitFor polls the condition until it is met or the timeout expires, the errors are logged and retried
+func (g *StartupGate) waitFor(stop <-chan struct{}, name string, timeout time.Duration, condition func() (bool, error)) {
+	if timeout <= 0 {
+		return
+	}
+	interval := g.PollInterval
+	if interval <= 0 {
+		interval = defaultStartupPollInterval
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	leaseLog.Info("Waiting for the startup condition", "condition", name, "timeout", timeout)
+	start := time.Now()
+	err := wait.PollImmediateUntil(interval, func() (bool, error) {
+		done, err := condition()
+		if err != nil {
+			leaseLog.Info("Waiting for the startup condition", "condition", name, "error", err.Error(), "errorClass", ErrorClass(err))
+			return false, nil
+		}
+		return done, nil
+	}, ctx.Done())
+	if err != nil {
+		leaseLog.Info("Stopped waiting for the startup condition, starting anyway", "condition", name, "duration", time.Since(start).Round(time.Second))
+		return
+	}
+	leaseLog.Info("Done waiting for the startup condition", "condition", name, "duration", time.Since(start).Round(time.Second))
+}
+
+// podReady checks if the pod is ready
+func (g *StartupGate) podReady() (bool, error) {
+	if g.PodName == "" || g.PodNamespace == "" {
+		return true, nil
+	}
+	pod := &corev1.Pod{}
+	if err := g.Reader.Get(context.TODO(), types.NamespacedName{Name: g.PodName, Namespace: g.PodNamespace}, pod); err != nil {
+		return false, err
+	}
+	if !isPodReady(pod) {
+		return false, fmt.Errorf("pod %s/%s is not ready", g.PodNamespace, g.PodName)
+	}
+	return true, nil
+}
+
+// hubSecretsPresent checks if all the hub secrets exist, the secrets found are added to secrets
+func (g *StartupGate) hubSecretsPresent(secrets map[string]*corev1.Secret) (bool, error) {
+	for _, name := range g.HubConfigSecretNames {
+		if name == "" || secrets[name] != nil {
+			continue
+		}
+		secret := &corev1.Secret{}
+		if err := g.Reader.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: g.HubConfigSecretNamespace}, secret); err != nil {
+			return false, err
+		}
+		secrets[name] = secret
+	}
+	return true, nil
+}
+
+// hubsReachable checks if the lease can be read on one of the API servers of each hub.
+// The hubs reached are removed from secrets, the hubs violating the hub connection policy are given up.
+func (g *StartupGate) hubsReachable(secrets map[string]*corev1.Secret) (bool, error) {
+	if g.BuildHubEndpointsWithSecretFunc == nil {
+		return true, nil
+	}
+	for name, secret := range secrets {
+		endpoints, err := g.BuildHubEndpointsWithSecretFunc(secret)
+		if err != nil {
+			if _, ok := err.(*HubPolicyError); ok {
+				leaseLog.Error(err, "Hub secret rejected, not waiting for the hub", "hub", name, "secret", secret.Namespace+"/"+name)
+				delete(secrets, name)
+				continue
+			}
+			return false, err
+		}
+		lastErr := fmt.Errorf("no hub server found")
+		for _, endpoint := range endpoints {
+			if lastErr = checkLeaseClient(context.TODO(), endpoint.Client, g.LeaseNamespace, g.LeaseName); lastErr == nil {
+				break
+			}
+		}
+		if lastErr != nil {
+			return false, fmt.Errorf("hub %s not reachable: %v", name, lastErr)
+		}
+		delete(secrets, name)
+	}
+	return true, nil
+}
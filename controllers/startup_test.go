@@ -0,0 +1,144 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestStartupGate_Wait(t *testing.T) {
+	readyPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: podNamespace},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	notReadyPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: podNamespace},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "hub-secret", Namespace: podNamespace},
+	}
+	reachable := func(secret *corev1.Secret) ([]HubEndpoint, error) {
+		return []HubEndpoint{{Client: fakekubeclient.NewSimpleClientset()}}, nil
+	}
+	unreachable := func(secret *corev1.Secret) ([]HubEndpoint, error) {
+		return nil, fmt.Errorf("connection refused")
+	}
+	rejected := func(secret *corev1.Secret) ([]HubEndpoint, error) {
+		return nil, &HubPolicyError{Reason: ReasonHubServerNotAllowed}
+	}
+	tests := []struct {
+		name      string
+		objects   []runtime.Object
+		buildFunc IBuildHubEndpointsWithSecret
+		// noLeaseNamespace is the derived lease namespace, unknown at startup
+		noLeaseNamespace bool
+		minDelay         time.Duration
+		wantMin          time.Duration
+		wantMax          time.Duration
+	}{
+		{
+			name:      "all conditions met",
+			objects:   []runtime.Object{readyPod, secret},
+			buildFunc: reachable,
+			wantMax:   400 * time.Millisecond,
+		},
+		{
+			name:      "minimum delay",
+			objects:   []runtime.Object{readyPod, secret},
+			buildFunc: reachable,
+			minDelay:  500 * time.Millisecond,
+			wantMin:   500 * time.Millisecond,
+			wantMax:   1500 * time.Millisecond,
+		},
+		{
+			name:      "pod not ready",
+			objects:   []runtime.Object{notReadyPod, secret},
+			buildFunc: reachable,
+			wantMin:   500 * time.Millisecond,
+			wantMax:   1500 * time.Millisecond,
+		},
+		{
+			name:      "hub secret missing",
+			objects:   []runtime.Object{readyPod},
+			buildFunc: reachable,
+			wantMin:   500 * time.Millisecond,
+			wantMax:   1500 * time.Millisecond,
+		},
+		{
+			name:      "hub not reachable",
+			objects:   []runtime.Object{readyPod, secret},
+			buildFunc: unreachable,
+			wantMin:   500 * time.Millisecond,
+			wantMax:   1500 * time.Millisecond,
+		},
+		{
+			name:             "lease namespace derived",
+			objects:          []runtime.Object{readyPod, secret},
+			buildFunc:        unreachable,
+			noLeaseNamespace: true,
+			wantMax:          400 * time.Millisecond,
+		},
+		{
+			name:      "hub secret rejected",
+			objects:   []runtime.Object{readyPod, secret},
+			buildFunc: rejected,
+			wantMax:   400 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &StartupGate{
+				Reader:                          fake.NewFakeClientWithScheme(scheme.Scheme, tt.objects...),
+				PodName:                         podName,
+				PodNamespace:                    podNamespace,
+				HubConfigSecretNames:            []string{"hub-secret"},
+				HubConfigSecretNamespace:        podNamespace,
+				LeaseName:                       "lease",
+				LeaseNamespace:                  "lease-ns",
+				BuildHubEndpointsWithSecretFunc: tt.buildFunc,
+				MinDelay:                        tt.minDelay,
+				PodReadyTimeout:                 500 * time.Millisecond,
+				HubSecretTimeout:                500 * time.Millisecond,
+				HubReachableTimeout:             500 * time.Millisecond,
+				PollInterval:                    50 * time.Millisecond,
+			}
+			if tt.noLeaseNamespace {
+				g.LeaseNamespace = ""
+			}
+			start := time.Now()
+			g.Wait(make(chan struct{}))
+			if elapsed := time.Since(start); elapsed < tt.wantMin || elapsed > tt.wantMax {
+				t.Errorf("StartupGate.Wait() took %s, want between %s and %s", elapsed, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestStartupGate_Wait_stop(t *testing.T) {
+	g := &StartupGate{
+		Reader:          fake.NewFakeClientWithScheme(scheme.Scheme),
+		PodName:         podName,
+		PodNamespace:    podNamespace,
+		MinDelay:        time.Minute,
+		PodReadyTimeout: time.Minute,
+		PollInterval:    50 * time.Millisecond,
+	}
+	stop := make(chan struct{})
+	close(stop)
+	start := time.Now()
+	g.Wait(stop)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("StartupGate.Wait() took %s after stop", elapsed)
+	}
+}
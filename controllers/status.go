@@ -0,0 +1,124 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultLeaseGraceFactor is the number of lease durations after which the hub considers the lease expired
+const DefaultLeaseGraceFactor = 5
+
+// LeaseStatus is the state of the lease on a hub, as seen by the hub, and of the pod
+type LeaseStatus struct {
+	// Hub is the name of the hub secret
+	Hub            string `json:"hub"`
+	Server         string `json:"server,omitempty"`
+	LeaseNamespace string `json:"leaseNamespace"`
+	LeaseName      string `json:"leaseName"`
+	Found          bool   `json:"found"`
+	HolderIdentity string `json:"holderIdentity,omitempty"`
+	// RenewTime is the last renew time, nil if never renewed
+	RenewTime            *time.Time `json:"renewTime,omitempty"`
+	LeaseDurationSeconds int32      `json:"leaseDurationSeconds,omitempty"`
+	// AgeSeconds is the time since the last renewal
+	AgeSeconds float64 `json:"ageSeconds,omitempty"`
+	// AgeDurations is the time since the last renewal in lease durations
+	AgeDurations float64 `json:"ageDurations,omitempty"`
+	// Expired is true if the hub considers the lease expired, its age is over the grace factor
+	Expired     bool    `json:"expired"`
+	GraceFactor float64 `json:"graceFactor"`
+	// PodReady is the readiness of the pod, nil if no pod is checked
+	PodReady *bool `json:"podReady,omitempty"`
+	// ClockSkewSeconds is the estimated clock skew with the hub, hub time minus local time, nil if unknown.
+	// The age of the lease is computed with the hub time.
+	ClockSkewSeconds *float64 `json:"clockSkewSeconds,omitempty"`
+	// MissingPermissions are the permissions required on the hub and not allowed, empty if the preflight is disabled
+	MissingPermissions []string `json:"missingPermissions,omitempty"`
+	Error              string   `json:"error,omitempty"`
+}
+
+// LeaseStatus reads the lease on the hub of the secret, from the first hub API server answering.
+// The lease is expired when it is not renewed within graceFactor lease durations.
+func (r *LeaseReconciler) LeaseStatus(ctx context.Context, secret *corev1.Secret, graceFactor float64, now time.Time) *LeaseStatus {
+	status := &LeaseStatus{
+		Hub:            secret.Name,
+		LeaseNamespace: r.LeaseNamespace,
+		LeaseName:      r.LeaseName,
+		GraceFactor:    graceFactor,
+	}
+	if r.PodName != "" && r.PodNamespace != "" && !r.SkipPodReadyCheck {
+		ready, err := r.checkPodIsRunning()
+		if err != nil {
+			status.Error = fmt.Sprintf("unable to get pod status: %v", err)
+			return status
+		}
+		status.PodReady = &ready
+	}
+
+	endpoints, err := r.buildHubEndpoints(secret)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	namespace, err := r.leaseNamespace(ctx, secret, endpoints[0].Client)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.LeaseNamespace = namespace
+	status.MissingPermissions = r.missingPermissions(ctx, &leaseUpdater{
+		hub:       secret.Name,
+		hubClient: endpoints[0].Client,
+		namespace: namespace,
+		name:      r.LeaseName,
+		owner:     r.OwnerAddOnName,
+	})
+	for _, endpoint := range endpoints {
+		status.Server = endpoint.Server
+		lease, err := endpoint.Client.CoordinationV1().Leases(namespace).Get(ctx, r.LeaseName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			status.Error = ""
+			status.Expired = true
+			return status
+		}
+		if err != nil {
+			status.Error = err.Error()
+			continue
+		}
+		status.Error = ""
+		status.Found = true
+		if skew, ok := endpoint.ClockSkew.Get(); ok {
+			seconds := skew.Seconds()
+			status.ClockSkewSeconds = &seconds
+			now = now.Add(skew)
+		}
+		if lease.Spec.HolderIdentity != nil {
+			status.HolderIdentity = *lease.Spec.HolderIdentity
+		}
+		if lease.Spec.LeaseDurationSeconds != nil {
+			status.LeaseDurationSeconds = *lease.Spec.LeaseDurationSeconds
+		}
+		if lease.Spec.RenewTime == nil {
+			status.Expired = true
+			return status
+		}
+		renewTime := lease.Spec.RenewTime.Time
+		status.RenewTime = &renewTime
+		age := now.Sub(renewTime)
+		status.AgeSeconds = age.Seconds()
+		if status.LeaseDurationSeconds > 0 {
+			duration := time.Duration(status.LeaseDurationSeconds) * time.Second
+			status.AgeDurations = float64(age) / float64(duration)
+			status.Expired = status.AgeDurations > graceFactor
+		}
+		return status
+	}
+	return status
+}
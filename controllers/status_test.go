@@ -0,0 +1,110 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLeaseReconciler_LeaseStatus(t *testing.T) {
+	now := time.Now()
+	duration := int32(60)
+	holder := "pod"
+	newLease := func(renewTime time.Time) *coordinationv1.Lease {
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: "addon-lease", Namespace: "cluster1"},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &duration,
+				RenewTime:            &metav1.MicroTime{Time: renewTime},
+			},
+		}
+	}
+	down := fakekubeclient.NewSimpleClientset()
+	down.PrependReactor("*", "leases", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("connection refused")
+	})
+	tests := []struct {
+		name             string
+		endpoints        []kubernetes.Interface
+		wantFound        bool
+		wantExpired      bool
+		wantAgeDurations float64
+		wantServer       string
+		wantErr          bool
+	}{
+		{
+			name:             "renewed",
+			endpoints:        []kubernetes.Interface{fakekubeclient.NewSimpleClientset(newLease(now.Add(-30 * time.Second)))},
+			wantFound:        true,
+			wantAgeDurations: 0.5,
+			wantServer:       "https://api0.hub.com",
+		},
+		{
+			name:             "expired",
+			endpoints:        []kubernetes.Interface{fakekubeclient.NewSimpleClientset(newLease(now.Add(-6 * time.Minute)))},
+			wantFound:        true,
+			wantExpired:      true,
+			wantAgeDurations: 6,
+			wantServer:       "https://api0.hub.com",
+		},
+		{
+			name:        "not found",
+			endpoints:   []kubernetes.Interface{fakekubeclient.NewSimpleClientset()},
+			wantExpired: true,
+			wantServer:  "https://api0.hub.com",
+		},
+		{
+			name:             "second server",
+			endpoints:        []kubernetes.Interface{down, fakekubeclient.NewSimpleClientset(newLease(now))},
+			wantFound:        true,
+			wantAgeDurations: 0,
+			wantServer:       "https://api1.hub.com",
+		},
+		{
+			name:       "hub down",
+			endpoints:  []kubernetes.Interface{down},
+			wantServer: "https://api0.hub.com",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &LeaseReconciler{
+				Client:         fake.NewFakeClientWithScheme(scheme.Scheme),
+				LeaseName:      "addon-lease",
+				LeaseNamespace: "cluster1",
+				BuildHubEndpointsWithSecretFunc: func(secret *corev1.Secret) ([]HubEndpoint, error) {
+					endpoints := []HubEndpoint{}
+					for i, c := range tt.endpoints {
+						endpoints = append(endpoints, HubEndpoint{Server: fmt.Sprintf("https://api%d.hub.com", i), Client: c})
+					}
+					return endpoints, nil
+				},
+			}
+			got := r.LeaseStatus(context.TODO(), &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "hub-secret"}}, DefaultLeaseGraceFactor, now)
+			if (got.Error != "") != tt.wantErr {
+				t.Errorf("LeaseReconciler.LeaseStatus() error = %s, wantErr %v", got.Error, tt.wantErr)
+			}
+			if got.Found != tt.wantFound || got.Expired != tt.wantExpired || got.AgeDurations != tt.wantAgeDurations || got.Server != tt.wantServer {
+				t.Errorf("LeaseReconciler.LeaseStatus() = %+v", got)
+			}
+			if got.Found && got.HolderIdentity != holder {
+				t.Errorf("LeaseReconciler.LeaseStatus() holder = %s, want %s", got.HolderIdentity, holder)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the tracer of the lease controller
+const tracerName = "github.com/stolostron/klusterlet-addon-lease-controller/controllers"
+
+// TracingOptions configures the export of the traces
+type TracingOptions struct {
+	// Endpoint is the URL of the OTLP/HTTP collector, such as http://collector:4318, the traces are not exported if empty.
+	// The http scheme disables TLS, the default path is /v1/traces.
+	Endpoint string
+	// SampleRatio is the ratio of the traces sampled, the child spans follow their parent
+	SampleRatio    float64
+	ServiceName    string
+	ServiceVersion string
+}
+
+// SetupTracing sets the global tracer provider exporting the traces to the OTLP collector of the options,
+// and the W3C trace context propagation to the hub. Without endpoint the global no-op tracer provider is kept.
+// The returned function flushes the traces and stops the export.
+func SetupTracing(ctx context.Context, o TracingOptions) (shutdown func(context.Context) error, err error) {
+	if o.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	endpoint, err := url.Parse(o.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q, expecting http(s)://host:port[/path]", o.Endpoint)
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint.Host)}
+	if endpoint.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if endpoint.Path != "" && endpoint.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(endpoint.Path))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the OTLP exporter: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(o.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(o.ServiceName),
+			semconv.ServiceVersionKey.String(o.ServiceVersion),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
+
+// tracer returns the tracer of the global tracer provider
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// spanAttributes returns the attributes of the spans of the lease updater, as its log keys
+func (u *leaseUpdater) spanAttributes() trace.SpanStartOption {
+	return trace.WithAttributes(
+		attribute.String("lease", u.name),
+		attribute.String("namespace", u.namespace),
+		attribute.String("hub", u.hub),
+	)
+}
+
+// endSpan records the error on the span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		recordSpanError(span, err)
+	}
+	span.End()
+}
+
+// recordSpanError records the error and its class on the span, and sets its status
+func recordSpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.SetAttributes(attribute.String("errorClass", ErrorClass(err)))
+}
+
+// TraceTransport wraps the transport of a hub client to trace the hub API calls,
+// a client span per request propagated to the hub
+func TraceTransport(rt http.RoundTripper) http.RoundTripper {
+	return &tracingTransport{rt: rt}
+}
+
+type tracingTransport struct {
+	rt http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracer().Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...))
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		endSpan(span, err)
+		return resp, err
+	}
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(resp.StatusCode))
+	span.End()
+	return resp, nil
+}
@@ -0,0 +1,132 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestLeaseUpdater_update_tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	traceparents := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		traceparents = append(traceparents, req.Header.Get("traceparent"))
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method == http.MethodPut {
+			body, _ := ioutil.ReadAll(req.Body)
+			w.Write(body)
+			return
+		}
+		json.NewEncoder(w).Encode(&coordinationv1.Lease{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"},
+			ObjectMeta: metav1.ObjectMeta{Name: leaseName, Namespace: leaseNamespace},
+		})
+	}))
+	defer server.Close()
+	hubClient, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL, WrapTransport: TraceTransport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &leaseUpdater{hub: "hub-secret", hubClient: hubClient, name: leaseName, namespace: leaseNamespace}
+	u.update(context.TODO())
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	update, ok := spans["leaseUpdater.update"]
+	if !ok {
+		t.Fatalf("leaseUpdater.update() spans = %v, want leaseUpdater.update", spans)
+	}
+	for _, name := range []string{"HTTP GET", "HTTP PUT"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("leaseUpdater.update() no %s span", name)
+			continue
+		}
+		if span.Parent().SpanID() != update.SpanContext().SpanID() {
+			t.Errorf("leaseUpdater.update() %s span is not a child of the update span", name)
+		}
+	}
+	if len(traceparents) != 2 || traceparents[0] == "" {
+		t.Errorf("leaseUpdater.update() traceparent headers = %v, want the trace propagated to the hub", traceparents)
+	}
+}
+
+func TestSetupTracing(t *testing.T) {
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	// the collector stand-in records the exported traces
+	lock := sync.Mutex{}
+	paths, bodies := []string{}, [][]byte{}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		paths = append(paths, req.URL.Path)
+		bodies = append(bodies, body)
+	}))
+	defer collector.Close()
+
+	tests := []struct {
+		name       string
+		endpoint   string
+		wantErr    bool
+		wantExport bool
+	}{
+		{name: "no endpoint", endpoint: ""},
+		{name: "invalid endpoint", endpoint: "collector:4318", wantErr: true},
+		{name: "collector", endpoint: collector.URL, wantExport: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, bodies = []string{}, [][]byte{}
+			shutdown, err := SetupTracing(context.TODO(), TracingOptions{Endpoint: tt.endpoint, SampleRatio: 1, ServiceName: "test"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetupTracing() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			_, span := tracer().Start(context.TODO(), "Reconcile")
+			span.End()
+			if err := shutdown(context.TODO()); err != nil {
+				t.Fatalf("shutdown() error = %v", err)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if !tt.wantExport {
+				if len(paths) != 0 {
+					t.Errorf("SetupTracing() exported %v, want no export", paths)
+				}
+				return
+			}
+			if len(paths) != 1 || paths[0] != "/v1/traces" {
+				t.Fatalf("SetupTracing() exported to %v, want /v1/traces", paths)
+			}
+			if !bytes.Contains(bodies[0], []byte("Reconcile")) {
+				t.Errorf("SetupTracing() exported traces without the Reconcile span")
+			}
+		})
+	}
+}
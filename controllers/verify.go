@@ -0,0 +1,94 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReasonLeaseConflict is the reason of the Event recorded when another agent renews the hub lease
+const ReasonLeaseConflict = "LeaseConflict"
+
+const (
+	// verifyResultVerified is the verification result when the lease is as last renewed
+	verifyResultVerified = "verified"
+	// verifyResultConflict is the verification result when another agent renews or holds the lease
+	verifyResultConflict = "conflict"
+	// verifyResultNotVisible is the verification result when the last renewal is not visible on the hub
+	verifyResultNotVisible = "not_visible"
+	// verifyResultError is the verification result when the lease can not be read
+	verifyResultError = "error"
+)
+
+// verify reads the lease again and checks the last renewal is visible on the hub and not overwritten
+// by another agent. The conflicts are logged and recorded as an Event when they start, and when they end.
+func (u *leaseUpdater) verify(ctx context.Context) {
+	u.updateLock.Lock()
+	defer u.updateLock.Unlock()
+	if ctx.Err() != nil || u.renewed == nil {
+		return
+	}
+	lease, err := u.getHubClient().CoordinationV1().Leases(u.namespace).Get(ctx, u.name, metav1.GetOptions{})
+	if err != nil {
+		u.log().Error(err, "Unable to verify the lease", "errorClass", ErrorClass(err))
+		leaseVerificationTotal.WithLabelValues(u.hub, u.namespace, u.name, verifyResultError).Inc()
+		return
+	}
+
+	result, message := verifyResultVerified, ""
+	holder := ""
+	if lease.Spec.HolderIdentity != nil {
+		holder = *lease.Spec.HolderIdentity
+	}
+	renewTime := time.Time{}
+	if lease.Spec.RenewTime != nil {
+		renewTime = lease.Spec.RenewTime.Time
+	}
+	// the renew time is stored with a microsecond precision
+	written := u.renewed.Time.Truncate(time.Microsecond)
+	switch {
+	case u.holderIdentity != "" && holder != u.holderIdentity:
+		result = verifyResultConflict
+		message = fmt.Sprintf("the lease %s/%s on hub %s is held by %q instead of %q", u.namespace, u.name, u.hub, holder, u.holderIdentity)
+	case renewTime.Truncate(time.Microsecond).After(written):
+		result = verifyResultConflict
+		message = fmt.Sprintf("the lease %s/%s on hub %s was renewed at %s by another agent, after the renewal at %s",
+			u.namespace, u.name, u.hub, renewTime.Format(time.RFC3339Nano), written.Format(time.RFC3339Nano))
+	case !renewTime.Truncate(time.Microsecond).Equal(written):
+		result = verifyResultNotVisible
+		u.log().Info("WARNING: the last renewal of the lease is not visible on the hub",
+			"renewed", written.Format(time.RFC3339Nano), "renewTime", renewTime.Format(time.RFC3339Nano))
+	}
+	leaseVerificationTotal.WithLabelValues(u.hub, u.namespace, u.name, result).Inc()
+	u.reportConflict(result == verifyResultConflict, message)
+}
+
+// reportConflict exposes the conflict condition of the lease, the changes are logged and recorded as Events
+func (u *leaseUpdater) reportConflict(conflict bool, message string) {
+	value := 0.0
+	if conflict {
+		value = 1
+	}
+	leaseConflict.WithLabelValues(u.hub, u.namespace, u.name).Set(value)
+	if conflict == u.conflict {
+		return
+	}
+	u.conflict = conflict
+	if conflict {
+		u.log().Info("WARNING: another agent renews the lease", "conflict", message)
+		if u.recordEvent != nil {
+			u.recordEvent(corev1.EventTypeWarning, ReasonLeaseConflict, message)
+		}
+		return
+	}
+	message = fmt.Sprintf("the lease %s/%s on hub %s is no longer renewed by another agent", u.namespace, u.name, u.hub)
+	u.log().Info("The conflict is resolved, the lease is no longer renewed by another agent")
+	if u.recordEvent != nil {
+		u.recordEvent(corev1.EventTypeNormal, ReasonLeaseConflict, message)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLeaseUpdater_verify(t *testing.T) {
+	renewed := metav1.NewMicroTime(time.Now().Add(-10 * time.Second))
+	newLease := func(holder string, renewTime time.Time) *coordinationv1.Lease {
+		lease := &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: leaseName, Namespace: leaseNamespace},
+		}
+		if holder != "" {
+			lease.Spec.HolderIdentity = &holder
+		}
+		if !renewTime.IsZero() {
+			lease.Spec.RenewTime = &metav1.MicroTime{Time: renewTime}
+		}
+		return lease
+	}
+	tests := []struct {
+		name         string
+		lease        *coordinationv1.Lease
+		conflict     bool
+		wantConflict bool
+		wantEvents   []string
+	}{
+		{
+			name:  "verified",
+			lease: newLease(podName, renewed.Time),
+		},
+		{
+			name:         "renewed by another agent",
+			lease:        newLease(podName, renewed.Add(5*time.Second)),
+			wantConflict: true,
+			wantEvents:   []string{"Warning LeaseConflict the lease " + leaseNamespace + "/" + leaseName + " on hub hub-secret was renewed at"},
+		},
+		{
+			name:         "held by another agent",
+			lease:        newLease("other-pod", renewed.Time),
+			wantConflict: true,
+			wantEvents:   []string{`Warning LeaseConflict the lease ` + leaseNamespace + "/" + leaseName + ` on hub hub-secret is held by "other-pod"`},
+		},
+		{
+			name:         "conflict already reported",
+			lease:        newLease("other-pod", renewed.Time),
+			conflict:     true,
+			wantConflict: true,
+		},
+		{
+			name:       "conflict resolved",
+			lease:      newLease(podName, renewed.Time),
+			conflict:   true,
+			wantEvents: []string{"Normal LeaseConflict the lease " + leaseNamespace + "/" + leaseName + " on hub hub-secret is no longer renewed by another agent"},
+		},
+		{
+			name:  "renewal not visible",
+			lease: newLease(podName, renewed.Add(-time.Minute)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := []string{}
+			hubClient := fakekubeclient.NewSimpleClientset(tt.lease)
+			u := &leaseUpdater{
+				hub:            "hub-secret",
+				hubClient:      hubClient,
+				name:           leaseName,
+				namespace:      leaseNamespace,
+				holderIdentity: podName,
+				renewed:        &renewed,
+				conflict:       tt.conflict,
+				recordEvent: func(eventType, reason, message string) {
+					events = append(events, strings.Join([]string{eventType, reason, message}, " "))
+				},
+			}
+			u.verify(context.TODO())
+			if u.conflict != tt.wantConflict {
+				t.Errorf("leaseUpdater.verify() conflict = %v, want %v", u.conflict, tt.wantConflict)
+			}
+			if len(events) != len(tt.wantEvents) {
+				t.Fatalf("leaseUpdater.verify() events = %v, want %v", events, tt.wantEvents)
+			}
+			for i := range events {
+				if !strings.HasPrefix(events[i], tt.wantEvents[i]) {
+					t.Errorf("leaseUpdater.verify() event = %q, want %q", events[i], tt.wantEvents[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLeaseUpdater_renew_verify(t *testing.T) {
+	hubClient := fakekubeclient.NewSimpleClientset(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: leaseName, Namespace: leaseNamespace},
+	})
+	u := &leaseUpdater{
+		hub:       "hub-secret",
+		hubClient: hubClient,
+		name:      leaseName,
+		namespace: leaseNamespace,
+	}
+	// nothing to verify before the first renewal
+	u.verify(context.TODO())
+	if err := u.renew(context.TODO()); err != nil {
+		t.Fatalf("leaseUpdater.renew() error = %v", err)
+	}
+	if u.renewed == nil {
+		t.Fatal("leaseUpdater.renew() renew time not recorded")
+	}
+	u.verify(context.TODO())
+	if u.conflict {
+		t.Error("leaseUpdater.verify() conflict after its own renewal")
+	}
+}
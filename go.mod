@@ -6,8 +6,14 @@ require (
 	github.com/go-logr/logr v0.2.1
 	github.com/onsi/ginkgo v1.12.1
 	github.com/onsi/gomega v1.10.1
+	github.com/prometheus/client_golang v1.7.1
 	github.com/stolostron/library-go v0.0.0-20220112062416-536980fdb526
-	golang.org/x/sys v0.0.0-20200625212154-ddb9806d33ae // indirect
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
+	go.uber.org/zap v1.10.0
+	golang.org/x/net v0.0.0-20200822124328-c89045814202
 	k8s.io/api v0.19.0
 	k8s.io/apiextensions-apiserver v0.19.0 // indirect
 	k8s.io/apimachinery v0.19.0
@@ -15,6 +21,7 @@ require (
 	k8s.io/klog v1.0.0
 	k8s.io/klog/v2 v2.3.0 // indirect
 	sigs.k8s.io/controller-runtime v0.6.2
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace github.com/go-logr/zapr => github.com/go-logr/zapr v0.2.0
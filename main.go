@@ -7,21 +7,34 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"reflect"
 	goruntime "runtime"
+	"strconv"
+	"strings"
 	"time"
 
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
 
 	"github.com/stolostron/klusterlet-addon-lease-controller/controllers"
 	"github.com/stolostron/klusterlet-addon-lease-controller/pkg/bindata"
+	"github.com/stolostron/klusterlet-addon-lease-controller/pkg/config"
 
 	corev1 "k8s.io/api/core/v1"
 	// +kubebuilder:scaffold:imports
@@ -49,10 +62,53 @@ func init() {
 	flag.StringVar(&metricsPort, "metrics-port", "8384", "The address the metric endpoint binds to.")
 	flag.StringVar(&leaseName, "lease-name", "", "The lease name")
 	flag.StringVar(&leaseNamespace, "lease-namespace", "", "The lease namespace")
-	flag.StringVar(&hubConfigSecretName, "hub-kubeconfig-secret", "", "The lease namespace")
+	flag.StringVar(&hubConfigSecretName, "hub-kubeconfig-secret", "", "The hub kubeconfig secret, a comma separated list to renew the lease on several hubs")
+	flag.StringVar(&hubServers, "hub-servers", "", "Comma separated list of hub API servers to fail over to, default the servers of the hub kubeconfig.")
+	flag.StringVar(&hubProxyURL, "hub-proxy-url", "", "The proxy URL to connect to the hub, default HTTPS_PROXY. NO_PROXY is honored.")
+	flag.StringVar(&hubCABundleConfigMap, "hub-ca-bundle-configmap", "", "The configmap ([namespace/]name) containing extra CAs trusted for the hub connection.")
+	flag.StringVar(&hubCABundleKey, "hub-ca-bundle-key", controllers.DefaultCABundleKey, "The key of the CA bundle in the hub-ca-bundle-configmap.")
+	flag.BoolVar(&hubRejectInsecure, "hub-reject-insecure", false, "Reject the hub kubeconfigs with insecure-skip-tls-verify, default false.")
+	flag.StringVar(&hubTLSMinVersion, "hub-tls-min-version", "", "The minimum TLS version of the hub connection (1.0, 1.1, 1.2 or 1.3), default the Go default.")
+	flag.StringVar(&hubCAFingerprints, "hub-ca-fingerprints", "", "Comma separated list of pinned SHA-256 fingerprints, every CA trusted for the hub, the CA bundle included, must match one of these fingerprints.")
+	flag.StringVar(&restartStrategy, "restart-strategy", string(controllers.RestartStrategyDeletePod), "How the pod is restarted when only the new hub secret works: DeletePod, RolloutRestart, AnnotatePod or None.")
+	flag.StringVar(&restartContainer, "restart-container", "", "The container signaled by the AnnotatePod restart strategy.")
+	flag.IntVar(&restartBudget, "restart-budget", 0, "The maximum number of restarts within the restart-budget-window, default 0 for no limit.")
+	flag.DurationVar(&restartBudgetWindow, "restart-budget-window", time.Hour, "The window of the restart-budget, default 1h.")
+	flag.StringVar(&hubAllowedServers, "hub-allowed-servers", "", "Comma separated list of allowed hub servers: hostnames (wildcards allowed), CIDRs or URL patterns, default all.")
 	flag.IntVar(&leaseDurationSeconds, "lease-duration", 60, "The lease duration in seconds, default 60 sec.")
-	flag.IntVar(&startupDelay, "startup-delay", 10, "The startup delay in seconds, default 10 sec.")
+	flag.IntVar(&startupDelay, "startup-delay", 0, "The minimum startup delay in seconds, default 0 sec.")
+	flag.DurationVar(&startupPodReadyTimeout, "startup-pod-ready-timeout", 2*time.Minute, "How long to wait for the pod to be ready before starting, 0 to not wait, default 2m.")
+	flag.DurationVar(&startupHubSecretTimeout, "startup-hub-secret-timeout", 2*time.Minute, "How long to wait for the hub kubeconfig secrets before starting, 0 to not wait, default 2m.")
+	flag.DurationVar(&startupHubReachableTimeout, "startup-hub-reachable-timeout", time.Minute, "How long to wait for the hubs to be reachable before starting, 0 to not wait, default 1m.")
 	flag.BoolVar(&enableLeaderElection, "leader-election", false, "Enable leader elction or not, default false.")
+	flag.StringVar(&leaderElectionNamespace, "leader-election-namespace", "", "The namespace of the leader election ConfigMap, default the pod namespace.")
+	flag.StringVar(&podName, "pod-name", "", "The pod name to check for readiness, default $POD_NAME.")
+	flag.StringVar(&podNamespace, "pod-namespace", "", "The pod namespace, default $POD_NAMESPACE.")
+	flag.StringVar(&secretNamespace, "watch-namespace", "", "The namespace of the hub kubeconfig secrets, default $WATCH_NAMESPACE or the pod namespace.")
+	flag.StringVar(&addonName, "addon-name", "", "The addon name, labeled on the hub lease.")
+	flag.StringVar(&clusterName, "cluster-name", "", "The managed cluster name, labeled on the hub lease.")
+	flag.StringVar(&leaseLabels, "lease-labels", "", "Comma separated list of key=value labels set on the hub lease.")
+	flag.StringVar(&leaseAnnotations, "lease-annotations", "", "Comma separated list of key=value annotations set on the hub lease.")
+	flag.BoolVar(&leaseOwnerAddOn, "lease-owner-addon", false, "Set the hub ManagedClusterAddOn addon-name as owner of the hub lease, default false.")
+	flag.BoolVar(&deriveLeaseNamespace, "derive-lease-namespace", false, "Derive the lease namespace from the hub kubeconfig context namespace, the client certificate or a SelfSubjectReview, lease-namespace is the fallback.")
+	flag.BoolVar(&hubPermissionsPreflight, "hub-permissions-preflight", true, "Review the lease permissions on the hub with SelfSubjectAccessReviews before renewing the lease, default true.")
+	flag.DurationVar(&clockSkewWarning, "clock-skew-warning", controllers.DefaultClockSkewWarning, "Log a warning when the clock skew with the hub is above this duration, 0 to not warn, default 5s.")
+	flag.BoolVar(&correctClockSkew, "correct-clock-skew", false, "Set the lease renew time to the hub time, estimated with the clock skew, default false.")
+	flag.DurationVar(&verifyRenewInterval, "verify-renew-interval", 0, "How often the lease is read again to verify the last renewal is visible on the hub and not overwritten by another agent, 0 to not verify, default 0.")
+	flag.StringVar(&configFile, "config", "", "A YAML file of parameters, the keys are the parameter names.")
+	flag.DurationVar(&configReloadInterval, "config-reload-interval", 30*time.Second, "How often the config file is checked for changes, 0 to not reload, default 30s.")
+	flag.DurationVar(&renewInterval, "renew-interval", 0, "The interval between the lease renewals, default the lease duration.")
+	flag.BoolVar(&skipPodReadyCheck, "skip-pod-ready-check", false, "Renew the lease even if the pod is not ready, default false.")
+	flag.BoolVar(&printConfig, "print-config", false, "Print the effective configuration and exit.")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "The URL of the OTLP/HTTP collector the traces are exported to, such as http://collector:4318, default none to not export the traces.")
+	flag.Float64Var(&traceSampleRatio, "trace-sample-ratio", 1, "The ratio of the traces sampled, between 0 and 1, default 1.")
+	flag.StringVar(&logFormat, "log-format", "console", "The log format, console or json, default console.")
+	flag.StringVar(&logLevel, "log-level", "info", "The log level, debug, info, error or a verbosity level above 0, default info.")
+	flag.BoolVar(&logSampling, "log-sampling", false, "Sample the logs, after the first 100 identical messages in a second only every 100th is logged, default false.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s [%s] [parameters]:\n", os.Args[0], strings.Join(subcommandNames(), "|"))
+		flag.PrintDefaults()
+	}
 }
 
 func printVersion() {
@@ -74,19 +130,91 @@ var metricsPort string
 var leaseName string
 var leaseNamespace string
 var hubConfigSecretName string
+var hubServers string
+var hubProxyURL string
+var hubCABundleConfigMap string
+var hubCABundleKey string
+var hubRejectInsecure bool
+var hubTLSMinVersion string
+var hubCAFingerprints string
+var hubAllowedServers string
+var restartStrategy string
+var restartContainer string
+var restartBudget int
+var restartBudgetWindow time.Duration
 var leaseDurationSeconds int
 var startupDelay int
+var startupPodReadyTimeout time.Duration
+var startupHubSecretTimeout time.Duration
+var startupHubReachableTimeout time.Duration
 var enableLeaderElection bool
+var leaderElectionNamespace string
+var podName string
+var podNamespace string
+var secretNamespace string
+var addonName string
+var clusterName string
+var leaseLabels string
+var leaseAnnotations string
+var leaseOwnerAddOn bool
+var deriveLeaseNamespace bool
+var hubPermissionsPreflight bool
+var clockSkewWarning time.Duration
+var correctClockSkew bool
+var verifyRenewInterval time.Duration
+var configFile string
+var configReloadInterval time.Duration
+var renewInterval time.Duration
+var skipPodReadyCheck bool
+var printConfig bool
+var otlpEndpoint string
+var traceSampleRatio float64
+var logFormat string
+var logLevel string
+var logSampling bool
+
+// configLoader sets the parameters not set on the command line from the environment and the config file
+var configLoader = &config.Loader{
+	EnvPrefix: "LEASE_CONTROLLER_",
+	LegacyEnv: map[string]string{
+		"pod-name":        "POD_NAME",
+		"pod-namespace":   "POD_NAMESPACE",
+		"watch-namespace": "WATCH_NAMESPACE",
+	},
+	ConfigFileFlag: "config",
+}
 
 func main() {
-	//The parse is set here in case we don't use the `go test`
-	flag.Parse()
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
 
-	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+	//The parse is set here in case we don't use the `go test`
+	if err := configLoader.Load(flag.CommandLine, os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%s\n", formatErrors(err))
+		os.Exit(1)
+	}
+	if printConfig {
+		if err := printEffectiveConfig(flag.CommandLine); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
-	if leaseName == "" || leaseNamespace == "" {
+	tlsMinVersion, strategy, err := validateParameters()
+	if err != nil {
 		flag.Usage()
-		setupLog.Error(fmt.Errorf("Missing parameters:"), "")
+		fmt.Fprintf(os.Stderr, "Invalid parameters:\n%s\n", formatErrors(err))
+		os.Exit(1)
+	}
+	logOpts, _ := loggerOptions()
+	ctrl.SetLogger(zap.New(logOpts...))
+	shutdownTracing, err := controllers.SetupTracing(context.Background(), tracingOptions())
+	if err != nil {
+		setupLog.Error(err, "unable to set up the tracing")
 		os.Exit(1)
 	}
 
@@ -98,42 +226,504 @@ func main() {
 
 	printVersion()
 
+	// a follower takes over the lease renewals within one renew interval
+	leaderLeaseDuration, leaderRenewDeadline, leaderRetryPeriod := controllers.LeaderElectionDurations(effectiveRenewInterval())
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		Namespace:          os.Getenv("WATCH_NAMESPACE"),
-		MetricsBindAddress: fmt.Sprintf("%s:%s", metricsHost, metricsPort),
-		Port:               operatorMetricsPort,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   leaseName + "-addon-lease.agent.stolostron.io",
+		Scheme:                  scheme,
+		Namespace:               watchNamespace(),
+		MetricsBindAddress:      fmt.Sprintf("%s:%s", metricsHost, metricsPort),
+		Port:                    operatorMetricsPort,
+		LeaderElection:          enableLeaderElection,
+		LeaderElectionID:        leaderElectionID(),
+		LeaderElectionNamespace: leaderElectionNamespaceName(),
+		LeaseDuration:           &leaderLeaseDuration,
+		RenewDeadline:           &leaderRenewDeadline,
+		RetryPeriod:             &leaderRetryPeriod,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.LeaseReconciler{
-		Client:                        mgr.GetClient(),
-		Log:                           ctrl.Log.WithName("controllers").WithName("Lease"),
-		Scheme:                        mgr.GetScheme(),
-		LeaseName:                     leaseName,
-		LeaseNamespace:                leaseNamespace,
-		LeaseDurationSeconds:          int32(leaseDurationSeconds),
-		HubConfigSecretName:           hubConfigSecretName,
-		BuildKubeClientWithSecretFunc: controllers.BuildKubeClientWithSecret,
-		CheckLeaseUpdaterClient:       controllers.CheckLeaseUpdaterClient,
-		PodName:                       os.Getenv("POD_NAME"),
-		PodNamespace:                  os.Getenv("POD_NAMESPACE"),
-	}).SetupWithManager(mgr); err != nil {
+	hubConfigSecretNames := splitList(hubConfigSecretName)
+	if len(hubConfigSecretNames) == 0 {
+		hubConfigSecretNames = []string{""}
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create kubernetes client")
+		os.Exit(1)
+	}
+
+	var podStatus *controllers.PodStatusCache
+	if podName != "" && podNamespace != "" {
+		podStatus = controllers.NewPodStatusCache(kubeClient, podNamespace, podName, 10*time.Minute)
+	}
+
+	// only the hub secrets are cached
+	secretCache := controllers.NewSecretCache(kubeClient, watchNamespace(), splitList(hubConfigSecretName), 10*time.Minute)
+
+	hubClientOptions := newHubClientOptions(mgr.GetAPIReader(), tlsMinVersion)
+	labels, annotations, _ := leaseMetadata()
+
+	leaseReconciler := &controllers.LeaseReconciler{
+		Client:                          mgr.GetClient(),
+		Log:                             ctrl.Log.WithName("controllers").WithName("Lease"),
+		Scheme:                          mgr.GetScheme(),
+		LeaseName:                       leaseName,
+		LeaseNamespace:                  leaseNamespace,
+		LeaseDurationSeconds:            int32(leaseDurationSeconds),
+		HubConfigSecretName:             hubConfigSecretNames[0],
+		AdditionalHubConfigSecretNames:  hubConfigSecretNames[1:],
+		BuildKubeClientWithSecretFunc:   hubClientOptions.BuildKubeClientWithSecret,
+		BuildHubEndpointsWithSecretFunc: hubClientOptions.BuildHubEndpointsWithSecret,
+		CheckLeaseUpdaterClient:         controllers.CheckLeaseUpdaterClient,
+		PodName:                         podName,
+		PodNamespace:                    podNamespace,
+		Recorder:                        mgr.GetEventRecorderFor("klusterlet-addon-lease-controller"),
+		RestartStrategy:                 strategy,
+		RestartContainer:                restartContainer,
+		PodStatus:                       podStatus,
+		SecretCache:                     secretCache,
+		APIReader:                       mgr.GetAPIReader(),
+		RestartBudget: controllers.RestartBudget{
+			MaxRestarts:   restartBudget,
+			Window:        restartBudgetWindow,
+			ConfigMapName: restartBudgetConfigMapName(),
+		},
+		RenewInterval:        renewInterval,
+		SkipPodReadyCheck:    skipPodReadyCheck,
+		LeaseLabels:          labels,
+		LeaseAnnotations:     annotations,
+		OwnerAddOnName:       ownerAddOnName(),
+		DeriveLeaseNamespace: deriveLeaseNamespace,
+		HolderIdentity:       holderIdentity(),
+		ClockSkewWarning:     clockSkewWarning,
+		CorrectClockSkew:     correctClockSkew,
+		VerifyRenewInterval:  verifyRenewInterval,
+	}
+	if hubPermissionsPreflight {
+		leaseReconciler.PreflightFunc = controllers.ReviewAccess
+	}
+	if err = leaseReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Lease")
 		os.Exit(1)
 	}
+	if configFile != "" && configReloadInterval > 0 {
+		if err := mgr.Add(&config.Watcher{
+			Loader:   configLoader,
+			FlagSet:  flag.CommandLine,
+			Args:     os.Args[1:],
+			File:     configFile,
+			Interval: configReloadInterval,
+			OnChange: func(fs *flag.FlagSet) { reloadConfig(leaseReconciler, fs) },
+			Log:      ctrl.Log.WithName("config"),
+			Content:  configLoader.Content(),
+		}); err != nil {
+			setupLog.Error(err, "unable to watch the config file")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
-	setupLog.Info(fmt.Sprintf("Waiting to startup... %d seconds", startupDelay))
-	time.Sleep(time.Duration(startupDelay) * time.Second)
+	stop := ctrl.SetupSignalHandler()
+	// the derived lease namespace is only known per hub secret, the hubs reachable condition is skipped
+	gateLeaseNamespace := leaseNamespace
+	if deriveLeaseNamespace {
+		gateLeaseNamespace = ""
+	}
+	(&controllers.StartupGate{
+		Reader:                          mgr.GetAPIReader(),
+		PodName:                         podName,
+		PodNamespace:                    podNamespace,
+		HubConfigSecretNames:            hubConfigSecretNames,
+		HubConfigSecretNamespace:        watchNamespace(),
+		LeaseName:                       leaseName,
+		LeaseNamespace:                  gateLeaseNamespace,
+		BuildHubEndpointsWithSecretFunc: hubClientOptions.BuildHubEndpointsWithSecret,
+		MinDelay:                        time.Duration(startupDelay) * time.Second,
+		PodReadyTimeout:                 startupPodReadyTimeout,
+		HubSecretTimeout:                startupHubSecretTimeout,
+		HubReachableTimeout:             startupHubReachableTimeout,
+	}).Wait(stop)
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	err = mgr.Start(stop)
+	flushTraces(shutdownTracing)
+	if err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// splitList splits a comma separated list, ignoring the empty items
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseNamespacedName parses a [namespace/]name value
+func parseNamespacedName(value, defaultNamespace string) types.NamespacedName {
+	if value == "" {
+		return types.NamespacedName{}
+	}
+	if i := strings.Index(value, "/"); i >= 0 {
+		return types.NamespacedName{Namespace: value[:i], Name: value[i+1:]}
+	}
+	return types.NamespacedName{Namespace: defaultNamespace, Name: value}
+}
+
+// watchNamespace returns the namespace of the hub secrets
+func watchNamespace() string {
+	if secretNamespace != "" {
+		return secretNamespace
+	}
+	return podNamespace
+}
+
+// validateParameters validates the controller parameters, all the invalid ones are reported
+func validateParameters() (tlsMinVersion uint16, strategy controllers.RestartStrategy, err error) {
+	errs := []error{}
+	if leaseName == "" {
+		errs = append(errs, fmt.Errorf("the lease-name parameter is required"))
+	}
+	if leaseNamespace == "" && !deriveLeaseNamespace {
+		errs = append(errs, fmt.Errorf("the lease-namespace parameter is required, unless derive-lease-namespace is set"))
+	}
+	// the hub secrets are never listed cluster-wide
+	if watchNamespace() == "" {
+		errs = append(errs, fmt.Errorf("the watch-namespace parameter is required, unless pod-namespace is set"))
+	}
+	if leaseDurationSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("the lease-duration parameter must be positive, got %d", leaseDurationSeconds))
+	}
+	if renewInterval < 0 {
+		errs = append(errs, fmt.Errorf("the renew-interval parameter must not be negative, got %s", renewInterval))
+	}
+	if verifyRenewInterval < 0 {
+		errs = append(errs, fmt.Errorf("the verify-renew-interval parameter must not be negative, got %s", verifyRenewInterval))
+	}
+	if _, err := loggerOptions(); err != nil {
+		errs = append(errs, err)
+	}
+	if traceSampleRatio < 0 || traceSampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("the trace-sample-ratio parameter must be between 0 and 1, got %v", traceSampleRatio))
+	}
+	if restartBudget < 0 {
+		errs = append(errs, fmt.Errorf("the restart-budget parameter must not be negative, got %d", restartBudget))
+	}
+	if restartBudget > 0 && restartBudgetWindow <= 0 {
+		errs = append(errs, fmt.Errorf("the restart-budget-window parameter must be positive with a restart budget, got %s", restartBudgetWindow))
+	}
+	if tlsMinVersion, err = controllers.ParseTLSVersion(hubTLSMinVersion); err != nil {
+		errs = append(errs, fmt.Errorf("hub-tls-min-version: %v", err))
+	}
+	if _, _, err := leaseMetadata(); err != nil {
+		errs = append(errs, err.(utilerrors.Aggregate).Errors()...)
+	}
+	// the servers are shared by all the hub secrets, the credentials of a hub must not be sent to the servers of another
+	if hubServers != "" && len(splitList(hubConfigSecretName)) > 1 {
+		errs = append(errs, fmt.Errorf("the hub-servers parameter can not be set with several hub-kubeconfig-secret"))
+	}
+	if leaseOwnerAddOn && addonName == "" {
+		errs = append(errs, fmt.Errorf("the lease-owner-addon parameter requires the addon-name parameter"))
+	}
+	strategy, err = controllers.ParseRestartStrategy(restartStrategy)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("restart-strategy: %v", err))
+	} else if strategy == controllers.RestartStrategyAnnotatePod && restartContainer == "" {
+		errs = append(errs, fmt.Errorf("the restart strategy %s requires the restart-container parameter", strategy))
+	} else if strategy == controllers.RestartStrategyAnnotatePod {
+		for _, msg := range validation.IsQualifiedName(controllers.ContainerRestartAnnotation(restartContainer)) {
+			errs = append(errs, fmt.Errorf("invalid restart annotation for the restart-container %q: %s", restartContainer, msg))
+		}
+	}
+	return tlsMinVersion, strategy, utilerrors.NewAggregate(errs)
+}
+
+// leaseMetadata returns the labels and annotations of the hub lease, all the invalid ones are reported
+func leaseMetadata() (labels, annotations map[string]string, err error) {
+	errs := []error{}
+	labels, labelErrs := parseKeyValues("lease-labels", leaseLabels, validation.IsValidLabelValue)
+	errs = append(errs, labelErrs...)
+	annotations, annotationErrs := parseKeyValues("lease-annotations", leaseAnnotations, nil)
+	errs = append(errs, annotationErrs...)
+	for key, value := range map[string]string{controllers.LabelAddonName: addonName, controllers.LabelClusterName: clusterName} {
+		if value == "" {
+			continue
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			errs = append(errs, fmt.Errorf("invalid label value %q for %s: %s", value, key, msg))
+		}
+		labels[key] = value
+	}
+	if version := componentVersion(); version != "" {
+		annotations[controllers.AnnotationControllerVersion] = version
+	}
+	return labels, annotations, utilerrors.NewAggregate(errs)
+}
+
+// parseKeyValues parses a comma separated list of key=value, the keys must be qualified names
+func parseKeyValues(parameter, list string, validateValue func(string) []string) (map[string]string, []error) {
+	values := map[string]string{}
+	errs := []error{}
+	for _, item := range splitList(list) {
+		i := strings.Index(item, "=")
+		if i < 0 {
+			errs = append(errs, fmt.Errorf("%s: %q is not a key=value pair", parameter, item))
+			continue
+		}
+		key, value := strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+		for _, msg := range validation.IsQualifiedName(key) {
+			errs = append(errs, fmt.Errorf("%s: invalid key %q: %s", parameter, key, msg))
+		}
+		if validateValue != nil {
+			for _, msg := range validateValue(value) {
+				errs = append(errs, fmt.Errorf("%s: invalid value %q for %s: %s", parameter, value, key, msg))
+			}
+		}
+		values[key] = value
+	}
+	return values, errs
+}
+
+// componentVersion returns the version of the controller, empty if not available
+func componentVersion() string {
+	v, err := bindata.Asset("COMPONENT_VERSION/COMPONENT_VERSION")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(v))
+}
+
+// ownerAddOnName returns the ManagedClusterAddOn owning the hub lease, empty if none
+func ownerAddOnName() string {
+	if leaseOwnerAddOn {
+		return addonName
+	}
+	return ""
+}
+
+// reloadableParameters are the parameters applied without restarting
+var reloadableParameters = map[string]bool{
+	"lease-name":           true,
+	"lease-namespace":      true,
+	"lease-duration":       true,
+	"renew-interval":       true,
+	"skip-pod-ready-check": true,
+}
+
+// isReloadable returns true if the parameter is applied without restarting. With leader election,
+// the renew interval requires a restart as the leader election durations are derived from it.
+func isReloadable(name string) bool {
+	if name == "renew-interval" && enableLeaderElection {
+		return false
+	}
+	return reloadableParameters[name]
+}
+
+// reloadConfig applies the lease settings of the reloaded configuration fs,
+// the changes of the other parameters are only reported as they require a restart
+func reloadConfig(r *controllers.LeaseReconciler, fs *flag.FlagSet) {
+	get := func(name string) interface{} {
+		return fs.Lookup(name).Value.(flag.Getter).Get()
+	}
+	settings := controllers.LeaseSettings{
+		LeaseName:            get("lease-name").(string),
+		LeaseNamespace:       get("lease-namespace").(string),
+		LeaseDurationSeconds: int32(get("lease-duration").(int)),
+		RenewInterval:        get("renew-interval").(time.Duration),
+		SkipPodReadyCheck:    get("skip-pod-ready-check").(bool),
+	}
+	errs := []error{}
+	if settings.LeaseName == "" {
+		errs = append(errs, fmt.Errorf("the lease-name parameter is required"))
+	}
+	if settings.LeaseNamespace == "" && !deriveLeaseNamespace {
+		errs = append(errs, fmt.Errorf("the lease-namespace parameter is required, unless derive-lease-namespace is set"))
+	}
+	if settings.LeaseDurationSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("the lease-duration parameter must be positive, got %d", settings.LeaseDurationSeconds))
+	}
+	if settings.RenewInterval < 0 {
+		errs = append(errs, fmt.Errorf("the renew-interval parameter must not be negative, got %s", settings.RenewInterval))
+	}
+	if err := utilerrors.NewAggregate(errs); err != nil {
+		setupLog.Error(err, "invalid configuration, the lease settings in use are kept")
+		return
+	}
+
+	running, reloaded := config.Effective(flag.CommandLine), config.Effective(fs)
+	for name, value := range reloaded {
+		if !isReloadable(name) && !reflect.DeepEqual(value, running[name]) {
+			setupLog.Info("The parameter change is applied on the next restart", "parameter", name, "value", value)
+		}
+	}
+	if enableLeaderElection {
+		// the leader election durations are derived from the renew interval at startup,
+		// the renewals keep this interval until the next restart
+		if settings.RenewInterval == 0 && time.Duration(settings.LeaseDurationSeconds)*time.Second != effectiveRenewInterval() {
+			setupLog.Info("The parameter change is applied on the next restart", "parameter", "renew-interval",
+				"value", time.Duration(settings.LeaseDurationSeconds)*time.Second)
+		}
+		settings.RenewInterval = effectiveRenewInterval()
+	}
+	r.ApplySettings(settings)
+}
+
+// loggerOptions returns the options of the logger set by the log-format, log-level and log-sampling parameters
+func loggerOptions() ([]zap.Opts, error) {
+	var encoder zapcore.Encoder
+	switch logFormat {
+	case "console":
+		encoder = zapcore.NewConsoleEncoder(uberzap.NewDevelopmentEncoderConfig())
+	case "json":
+		encoder = zapcore.NewJSONEncoder(uberzap.NewProductionEncoderConfig())
+	default:
+		return nil, fmt.Errorf("the log-format parameter must be console or json, got %q", logFormat)
+	}
+	level, err := parseLogLevel(logLevel)
+	if err != nil {
+		return nil, err
+	}
+	opts := []zap.Opts{
+		// the development mode only disables the built-in sampling, set by log-sampling instead
+		zap.UseDevMode(true),
+		zap.Encoder(encoder),
+		zap.Level(uberzap.NewAtomicLevelAt(level)),
+		zap.StacktraceLevel(uberzap.NewAtomicLevelAt(zapcore.ErrorLevel)),
+	}
+	if logSampling {
+		opts = append(opts, zap.RawZapOpts(uberzap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewSampler(core, time.Second, 100, 100)
+		})))
+	}
+	return opts, nil
+}
+
+// parseLogLevel parses a log level, debug, info, error or a verbosity level above 0 enabling the V(level) logs
+func parseLogLevel(level string) (zapcore.Level, error) {
+	switch level {
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	}
+	verbosity, err := strconv.Atoi(level)
+	if err != nil || verbosity <= 0 {
+		return 0, fmt.Errorf("the log-level parameter must be debug, info, error or a verbosity level above 0, got %q", level)
+	}
+	return zapcore.Level(-verbosity), nil
+}
+
+// tracingOptions returns the options of the trace export set by the otlp-endpoint and trace-sample-ratio parameters
+func tracingOptions() controllers.TracingOptions {
+	return controllers.TracingOptions{
+		Endpoint:       otlpEndpoint,
+		SampleRatio:    traceSampleRatio,
+		ServiceName:    "klusterlet-addon-lease-controller",
+		ServiceVersion: componentVersion(),
+	}
+}
+
+// flushTraces exports the pending traces before exiting
+func flushTraces(shutdown func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdown(ctx); err != nil {
+		setupLog.Error(err, "unable to export the pending traces")
+	}
+}
+
+// formatErrors formats the errors, one per line
+func formatErrors(err error) string {
+	if agg, ok := err.(utilerrors.Aggregate); ok {
+		lines := []string{}
+		for _, e := range agg.Errors() {
+			lines = append(lines, "  - "+e.Error())
+		}
+		return strings.Join(lines, "\n")
+	}
+	return "  - " + err.Error()
+}
+
+// printEffectiveConfig prints the effective parameters as a YAML config file
+func printEffectiveConfig(fs *flag.FlagSet) error {
+	values := config.Effective(fs)
+	delete(values, "print-config")
+	delete(values, "config")
+	for name := range values {
+		// the go test flags
+		if strings.HasPrefix(name, "test.") {
+			delete(values, name)
+		}
+	}
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+// leaderElectionID returns the name of the leader election ConfigMap
+func leaderElectionID() string {
+	return leaseName + "-addon-lease.agent.stolostron.io"
+}
+
+// leaderElectionNamespaceName returns the namespace of the leader election ConfigMap
+func leaderElectionNamespaceName() string {
+	if leaderElectionNamespace != "" {
+		return leaderElectionNamespace
+	}
+	return podNamespace
+}
+
+// effectiveRenewInterval returns the interval between the hub lease renewals
+func effectiveRenewInterval() time.Duration {
+	if renewInterval > 0 {
+		return renewInterval
+	}
+	return time.Duration(leaseDurationSeconds) * time.Second
+}
+
+// holderIdentity returns the holder identity of the hub lease, the pod name or the host name
+func holderIdentity() string {
+	if podName != "" {
+		return podName
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return hostname
+}
+
+// restartBudgetConfigMapName returns the name of the ConfigMap persisting the restart budget
+func restartBudgetConfigMapName() string {
+	return leaseName + "-restart-budget"
+}
+
+// newHubClientOptions returns the hub client options defined by the parameters
+func newHubClientOptions(reader client.Reader, tlsMinVersion uint16) *controllers.HubClientOptions {
+	return &controllers.HubClientOptions{
+		Servers:           splitList(hubServers),
+		ProxyURL:          hubProxyURL,
+		CABundleConfigMap: parseNamespacedName(hubCABundleConfigMap, watchNamespace()),
+		CABundleKey:       hubCABundleKey,
+		Reader:            reader,
+		TLSPolicy: controllers.HubTLSPolicy{
+			RejectInsecure: hubRejectInsecure,
+			MinVersion:     tlsMinVersion,
+			CAFingerprints: splitList(hubCAFingerprints),
+		},
+		AllowedServers: splitList(hubAllowedServers),
+	}
+}
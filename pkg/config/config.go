@@ -0,0 +1,180 @@
+// Copyright Contributors to the Open Cluster Management project
+
+// Package config sets the flags of a flag set from the command line, the environment and a YAML file.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// Loader sets the flags, by order of precedence, from:
+//   - the command line
+//   - the environment variables, EnvPrefix followed by the flag name in upper case with - replaced by _,
+//     or the legacy environment variable of the flag
+//   - the YAML config file, a map of flag names to values
+//   - the flag default
+type Loader struct {
+	EnvPrefix string
+	// LegacyEnv maps flag names to the environment variables used before the EnvPrefix ones
+	LegacyEnv map[string]string
+	// ConfigFileFlag is the name of the flag defining the config file
+	ConfigFileFlag string
+
+	content []byte
+}
+
+// Content returns the content of the config file read by the last Load, nil without config file
+func (l *Loader) Content() []byte {
+	return l.content
+}
+
+// EnvName returns the environment variable of the flag
+func (l *Loader) EnvName(flagName string) string {
+	return l.EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Load parses the arguments and sets the flags not set on the command line from the environment and the config file.
+// All the invalid values are reported at once.
+func (l *Loader) Load(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	setOnCommandLine := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	errs := []error{}
+	fromEnv := map[string]string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		if setOnCommandLine[f.Name] {
+			return
+		}
+		if value, ok := os.LookupEnv(l.EnvName(f.Name)); ok {
+			fromEnv[f.Name] = value
+		} else if legacy, ok := l.LegacyEnv[f.Name]; ok {
+			if value, ok := os.LookupEnv(legacy); ok {
+				fromEnv[f.Name] = value
+			}
+		}
+	})
+
+	// the config file may be defined by the environment
+	if value, ok := fromEnv[l.ConfigFileFlag]; ok && l.ConfigFileFlag != "" {
+		if err := fs.Set(l.ConfigFileFlag, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", l.EnvName(l.ConfigFileFlag), err))
+		}
+	}
+	l.content = nil
+	if f := fs.Lookup(l.ConfigFileFlag); f != nil && f.Value.String() != "" {
+		content, fileValues, err := readFile(f.Value.String())
+		if err != nil {
+			return err
+		}
+		l.content = content
+		for _, name := range sortedKeys(fileValues) {
+			if fs.Lookup(name) == nil {
+				errs = append(errs, fmt.Errorf("%s: unknown option %q", f.Value.String(), name))
+				continue
+			}
+			if setOnCommandLine[name] || name == l.ConfigFileFlag {
+				continue
+			}
+			if _, ok := fromEnv[name]; ok {
+				continue
+			}
+			if err := fs.Set(name, fileValues[name]); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid value %q for %s: %v", f.Value.String(), fileValues[name], name, err))
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(fromEnv) {
+		if name == l.ConfigFileFlag {
+			continue
+		}
+		if err := fs.Set(name, fromEnv[name]); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q for %s: %v", fromEnv[name], l.envNameSet(name), err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// envNameSet returns the environment variable which defined the flag
+func (l *Loader) envNameSet(flagName string) string {
+	if _, ok := os.LookupEnv(l.EnvName(flagName)); !ok {
+		if legacy, ok := l.LegacyEnv[flagName]; ok {
+			return legacy
+		}
+	}
+	return l.EnvName(flagName)
+}
+
+// Effective returns the values of all the flags, for printing the effective configuration
+func Effective(fs *flag.FlagSet) map[string]interface{} {
+	values := map[string]interface{}{}
+	fs.VisitAll(func(f *flag.Flag) {
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			values[f.Name] = f.Value.String()
+			return
+		}
+		switch value := getter.Get().(type) {
+		case time.Duration:
+			values[f.Name] = value.String()
+		default:
+			values[f.Name] = value
+		}
+	})
+	return values
+}
+
+// readFile reads the YAML config file as flag values, with its content
+func readFile(file string) ([]byte, map[string]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read the config file: %v", err)
+	}
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, nil, fmt.Errorf("unable to parse the config file %s: %v", file, err)
+	}
+	values := map[string]string{}
+	for name, value := range raw {
+		switch v := value.(type) {
+		case []interface{}:
+			// lists are comma separated flag values
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[name] = strings.Join(items, ",")
+		case float64:
+			// YAML numbers are decoded as float64 by the JSON conversion
+			values[name] = fmt.Sprint(v)
+		case nil:
+			values[name] = ""
+		default:
+			values[name] = fmt.Sprint(v)
+		}
+	}
+	return data, values, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,205 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newFlagSet() (*flag.FlagSet, map[string]interface{}) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.String("config", "", "")
+	fs.String("lease-name", "", "")
+	fs.Int("lease-duration", 60, "")
+	fs.Duration("timeout", time.Minute, "")
+	fs.Bool("leader-election", false, "")
+	fs.String("hub-kubeconfig-secret", "", "")
+	fs.String("pod-name", "", "")
+	return fs, Effective(fs)
+}
+
+func TestLoader_Load(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	configFile := writeFile("config.yaml", `
+lease-name: from-file
+lease-duration: 30
+timeout: 5m
+leader-election: true
+hub-kubeconfig-secret:
+- secret1
+- secret2
+`)
+	invalidFile := writeFile("invalid.yaml", `
+lease-duration: abc
+unknown: value
+timeout: 1x
+`)
+
+	type args struct {
+		args []string
+		env  map[string]string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    map[string]interface{}
+		wantErr []string
+	}{
+		{
+			name: "defaults",
+			args: args{},
+			want: map[string]interface{}{},
+		},
+		{
+			name: "file",
+			args: args{
+				args: []string{"-config", configFile},
+			},
+			want: map[string]interface{}{
+				"config":                configFile,
+				"lease-name":            "from-file",
+				"lease-duration":        30,
+				"timeout":               "5m0s",
+				"leader-election":       true,
+				"hub-kubeconfig-secret": "secret1,secret2",
+			},
+		},
+		{
+			name: "file from env",
+			args: args{
+				env: map[string]string{"TEST_CONFIG": configFile},
+			},
+			want: map[string]interface{}{
+				"config":                configFile,
+				"lease-name":            "from-file",
+				"lease-duration":        30,
+				"timeout":               "5m0s",
+				"leader-election":       true,
+				"hub-kubeconfig-secret": "secret1,secret2",
+			},
+		},
+		{
+			name: "precedence",
+			args: args{
+				args: []string{"-config", configFile, "-lease-name", "from-flag"},
+				env: map[string]string{
+					"TEST_LEASE_NAME":     "from-env",
+					"TEST_LEASE_DURATION": "45",
+					"POD_NAME":            "legacy",
+				},
+			},
+			want: map[string]interface{}{
+				"config":                configFile,
+				"lease-name":            "from-flag",
+				"lease-duration":        45,
+				"timeout":               "5m0s",
+				"leader-election":       true,
+				"hub-kubeconfig-secret": "secret1,secret2",
+				"pod-name":              "legacy",
+			},
+		},
+		{
+			name: "prefixed env before legacy env",
+			args: args{
+				env: map[string]string{
+					"TEST_POD_NAME": "prefixed",
+					"POD_NAME":      "legacy",
+				},
+			},
+			want: map[string]interface{}{
+				"pod-name": "prefixed",
+			},
+		},
+		{
+			name: "all errors",
+			args: args{
+				args: []string{"-config", invalidFile},
+				env: map[string]string{
+					"TEST_LEADER_ELECTION": "maybe",
+				},
+			},
+			wantErr: []string{
+				`invalid value "abc" for lease-duration`,
+				`unknown option "unknown"`,
+				`invalid value "1x" for timeout`,
+				`invalid value "maybe" for TEST_LEADER_ELECTION`,
+			},
+		},
+		{
+			name: "missing file",
+			args: args{
+				args: []string{"-config", filepath.Join(dir, "missing.yaml")},
+			},
+			wantErr: []string{"unable to read the config file"},
+		},
+		{
+			name: "invalid flag",
+			args: args{
+				args: []string{"-lease-duration", "abc"},
+			},
+			wantErr: []string{"invalid value"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.args.env {
+				os.Setenv(name, value)
+			}
+			defer func() {
+				for name := range tt.args.env {
+					os.Unsetenv(name)
+				}
+			}()
+			fs, want := newFlagSet()
+			for name, value := range tt.want {
+				want[name] = value
+			}
+			l := &Loader{
+				EnvPrefix:      "TEST_",
+				LegacyEnv:      map[string]string{"pod-name": "POD_NAME"},
+				ConfigFileFlag: "config",
+			}
+			err := l.Load(fs, tt.args.args)
+			if (err != nil) != (len(tt.wantErr) != 0) {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				for _, wantErr := range tt.wantErr {
+					if !strings.Contains(err.Error(), wantErr) {
+						t.Errorf("Load() error = %v, want %q", err, wantErr)
+					}
+				}
+				return
+			}
+			if got := Effective(fs); !reflect.DeepEqual(got, want) {
+				t.Errorf("Effective() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoader_EnvName(t *testing.T) {
+	l := &Loader{EnvPrefix: "LEASE_CONTROLLER_"}
+	if got := l.EnvName("hub-kubeconfig-secret"); got != "LEASE_CONTROLLER_HUB_KUBECONFIG_SECRET" {
+		t.Errorf("EnvName() = %s", got)
+	}
+}
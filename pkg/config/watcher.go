@@ -0,0 +1,100 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package config
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Watcher polls the config file, for example mounted from a ConfigMap, and loads the configuration again when it changes.
+// It implements the manager Runnable interface.
+type Watcher struct {
+	Loader *Loader
+	// FlagSet defines the flags, the configuration is loaded into a copy of it
+	FlagSet *flag.FlagSet
+	// Args are the command line arguments, they still have the precedence
+	Args     []string
+	File     string
+	Interval time.Duration
+	// OnChange is called with the copy of the flag set loaded with the new configuration
+	OnChange func(fs *flag.FlagSet)
+	Log      logr.Logger
+	// Content is the content of the config file the configuration in use was loaded from, see Loader.Content.
+	// The file may change before Start, such changes are loaded by the first poll.
+	Content []byte
+
+	content []byte
+}
+
+// Start polls the config file until stop is closed
+func (w *Watcher) Start(stop <-chan struct{}) error {
+	w.content = w.Content
+	wait.Until(w.poll, w.Interval, stop)
+	return nil
+}
+
+// poll loads the configuration if the config file changed, an invalid configuration is ignored
+func (w *Watcher) poll() {
+	content, err := ioutil.ReadFile(w.File)
+	if err != nil {
+		w.Log.Error(err, "unable to read the config file", "file", w.File)
+		return
+	}
+	if bytes.Equal(content, w.content) {
+		return
+	}
+	w.content = content
+	fs := Copy(w.FlagSet)
+	if err := w.Loader.Load(fs, w.Args); err != nil {
+		w.Log.Error(err, "invalid configuration, the configuration in use is kept", "file", w.File)
+		return
+	}
+	w.Log.Info("configuration changed", "file", w.File)
+	w.OnChange(fs)
+}
+
+// Copy returns a flag set with the same flags set to their default values, not sharing the values of fs.
+// The flags which are not of a basic type share their value.
+func Copy(fs *flag.FlagSet) *flag.FlagSet {
+	c := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	c.SetOutput(ioutil.Discard)
+	fs.VisitAll(func(f *flag.Flag) {
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			c.Var(f.Value, f.Name, f.Usage)
+			return
+		}
+		switch getter.Get().(type) {
+		case string:
+			c.String(f.Name, f.DefValue, f.Usage)
+		case bool:
+			c.Bool(f.Name, false, f.Usage)
+		case int:
+			c.Int(f.Name, 0, f.Usage)
+		case int64:
+			c.Int64(f.Name, 0, f.Usage)
+		case uint:
+			c.Uint(f.Name, 0, f.Usage)
+		case uint64:
+			c.Uint64(f.Name, 0, f.Usage)
+		case float64:
+			c.Float64(f.Name, 0, f.Usage)
+		case time.Duration:
+			c.Duration(f.Name, 0, f.Usage)
+		default:
+			c.Var(f.Value, f.Name, f.Usage)
+			return
+		}
+		copied := c.Lookup(f.Name)
+		if err := copied.Value.Set(f.DefValue); err == nil {
+			copied.DefValue = f.DefValue
+		}
+	})
+	return c
+}
@@ -0,0 +1,144 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestCopy(t *testing.T) {
+	fs, _ := newFlagSet()
+	if err := fs.Parse([]string{"-lease-name", "set", "-timeout", "5m"}); err != nil {
+		t.Fatal(err)
+	}
+	c := Copy(fs)
+	if got := c.Lookup("lease-name").Value.String(); got != "" {
+		t.Errorf("Copy() lease-name = %q, want the default", got)
+	}
+	if got := c.Lookup("timeout").Value.String(); got != "1m0s" {
+		t.Errorf("Copy() timeout = %q, want the default 1m0s", got)
+	}
+	if err := c.Set("lease-duration", "10"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.Lookup("lease-duration").Value.String(); got != "60" {
+		t.Errorf("Copy() shares the value of lease-duration, %s", got)
+	}
+}
+
+func TestWatcher_poll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(file, []byte("lease-duration: 30\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, _ := newFlagSet()
+	args := []string{"-config", file, "-lease-name", "from-flag"}
+	loader := &Loader{EnvPrefix: "TEST_", ConfigFileFlag: "config"}
+	if err := loader.Load(fs, args); err != nil {
+		t.Fatal(err)
+	}
+	changes := []*flag.FlagSet{}
+	w := &Watcher{
+		Loader:   loader,
+		FlagSet:  fs,
+		Args:     args,
+		File:     file,
+		Interval: time.Second,
+		OnChange: func(fs *flag.FlagSet) { changes = append(changes, fs) },
+		Log:      ctrl.Log.WithName("config"),
+	}
+	w.content = loader.Content()
+
+	w.poll()
+	if len(changes) != 0 {
+		t.Errorf("Watcher.poll() %d changes for the same content", len(changes))
+	}
+
+	if err := ioutil.WriteFile(file, []byte("lease-duration: 45\nlease-name: from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	w.poll()
+	if len(changes) != 1 {
+		t.Fatalf("Watcher.poll() %d changes, want 1", len(changes))
+	}
+	if got := changes[0].Lookup("lease-duration").Value.String(); got != "45" {
+		t.Errorf("Watcher.poll() lease-duration = %s, want 45", got)
+	}
+	if got := changes[0].Lookup("lease-name").Value.String(); got != "from-flag" {
+		t.Errorf("Watcher.poll() lease-name = %s, the command line must have the precedence", got)
+	}
+	if got := fs.Lookup("lease-duration").Value.String(); got != "30" {
+		t.Errorf("Watcher.poll() changed the flag set in use, lease-duration = %s", got)
+	}
+
+	if err := ioutil.WriteFile(file, []byte("lease-duration: abc\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	w.poll()
+	if len(changes) != 1 {
+		t.Errorf("Watcher.poll() %d changes, an invalid configuration must be ignored", len(changes))
+	}
+}
+
+func TestWatcher_Start_changedBeforeStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(file, []byte("lease-duration: 30\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, _ := newFlagSet()
+	args := []string{"-config", file}
+	loader := &Loader{EnvPrefix: "TEST_", ConfigFileFlag: "config"}
+	if err := loader.Load(fs, args); err != nil {
+		t.Fatal(err)
+	}
+	// the file changes after the configuration is loaded, before the watcher starts
+	if err := ioutil.WriteFile(file, []byte("lease-duration: 45\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	changes := []*flag.FlagSet{}
+	w := &Watcher{
+		Loader:   loader,
+		FlagSet:  fs,
+		Args:     args,
+		File:     file,
+		Interval: 10 * time.Millisecond,
+		OnChange: func(fs *flag.FlagSet) {
+			changes = append(changes, fs)
+			close(stop)
+		},
+		Log:     ctrl.Log.WithName("config"),
+		Content: loader.Content(),
+	}
+	timer := time.AfterFunc(5*time.Second, func() { close(stop) })
+	defer timer.Stop()
+	if err := w.Start(stop); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("Watcher.Start() %d changes, the change before Start must be loaded", len(changes))
+	}
+	if got := changes[0].Lookup("lease-duration").Value.String(); got != "45" {
+		t.Errorf("Watcher.Start() lease-duration = %s, want 45", got)
+	}
+}